@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+)
+
+// ErrNotCached is returned by an offline CachingClient for anything that is not in its cache.
+var ErrNotCached = errors.New("not in the cache")
+
+// CachingClient keeps the blocks, pages and block children it fetches in a directory on disk,
+// and only fetches a block's children again once the block has been edited since, so that pages
+// can be rendered again and again without fetching all of their content each time. Offline, it
+// answers from the cache alone and never sends a request.
+//
+// A block has to be fetched to find out when it was last edited, so online every fetch of block
+// children costs a request even when they are cached. Notion keeps edit times to the minute, so an
+// edit made within a minute of a block being cached can go unnoticed until the block is edited again.
+type CachingClient struct {
+	API NotionAPI
+	// Dir is the directory the responses are kept in.
+	Dir string
+	// Offline answers every request from the cache, failing with ErrNotCached for anything missing.
+	Offline bool
+}
+
+var _ NotionAPI = (*CachingClient)(nil)
+
+// NewCachingClient wraps apiClient with a cache kept in dir.
+func NewCachingClient(apiClient NotionAPI, dir string, offline bool) *CachingClient {
+	return &CachingClient{API: apiClient, Dir: dir, Offline: offline}
+}
+
+// cacheEntry is a response in the cache, along with the edit time of the object it belongs to.
+type cacheEntry struct {
+	LastEditedTime string          `json:"last_edited_time"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// The kinds of response, each kept in a directory of its own.
+const (
+	cacheBlocks   = "blocks"
+	cachePages    = "pages"
+	cacheChildren = "children"
+)
+
+func (c *CachingClient) path(kind, id string) string {
+	return filepath.Join(c.Dir, kind, NormalizeID(id)+".json")
+}
+
+// load reads a response from the cache into v, and returns the edit time it was stored with.
+// It returns false when there is none, or it cannot be read.
+func (c *CachingClient) load(kind, id string, v any) (string, bool) {
+	data, err := os.ReadFile(c.path(kind, id))
+	if err != nil {
+		return "", false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", false
+	}
+	if err := json.Unmarshal(entry.Data, v); err != nil {
+		return "", false
+	}
+	return entry.LastEditedTime, true
+}
+
+// store writes a response to the cache. The response was fetched already, so failing to cache it is not an error.
+func (c *CachingClient) store(kind, id, lastEdited string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	data, err = json.Marshal(cacheEntry{LastEditedTime: lastEdited, Data: data})
+	if err != nil {
+		return
+	}
+	path := c.path(kind, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	atomicfile.WriteFile(path, data, 0644)
+}
+
+func (c *CachingClient) notCached(kind, id string) error {
+	return fmt.Errorf("%s %s: %w", kind, id, ErrNotCached)
+}
+
+// GetNotionBlock fetches a block and caches it. Offline, it returns the block as it was last cached.
+func (c *CachingClient) GetNotionBlock(blockID, bearerToken string) (*Block, error) {
+	if c.Offline {
+		var block Block
+		if _, ok := c.load(cacheBlocks, blockID, &block); !ok {
+			return nil, c.notCached("block", blockID)
+		}
+		return &block, nil
+	}
+	block, err := c.API.GetNotionBlock(blockID, bearerToken)
+	if err != nil {
+		return nil, err
+	}
+	c.store(cacheBlocks, blockID, block.LastEditedTime, block)
+	return block, nil
+}
+
+// GetNotionBlockTitle reads the title from the block, so that the block is cached along the way.
+func (c *CachingClient) GetNotionBlockTitle(blockID, bearerToken string) (string, error) {
+	block, err := c.GetNotionBlock(blockID, bearerToken)
+	if err != nil {
+		return "", err
+	}
+	if block.ChildPage == nil {
+		return "", nil
+	}
+	return block.ChildPage.Title, nil
+}
+
+// GetNotionChildBlocks returns the cached children of a block when the block has not been edited
+// since they were cached, and fetches and caches them otherwise. Children holding files hosted by
+// Notion are fetched again once the signed URLs of the files expire, so that the files can still be
+// downloaded. Offline, the cached children are returned however old they are.
+func (c *CachingClient) GetNotionChildBlocks(blockID, bearerToken string) (*ResultsWrapper, error) {
+	var cached ResultsWrapper
+	cachedEdit, ok := c.load(cacheChildren, blockID, &cached)
+	if c.Offline {
+		if !ok {
+			return nil, c.notCached("children of block", blockID)
+		}
+		return &cached, nil
+	}
+
+	block, err := c.GetNotionBlock(blockID, bearerToken)
+	if err != nil {
+		return nil, err
+	}
+	if ok && block.LastEditedTime != "" && cachedEdit == block.LastEditedTime && !filesExpired(&cached, time.Now()) {
+		return &cached, nil
+	}
+	results, err := c.API.GetNotionChildBlocks(blockID, bearerToken)
+	if err != nil {
+		return nil, err
+	}
+	c.store(cacheChildren, blockID, block.LastEditedTime, results)
+	return results, nil
+}
+
+// expiryMargin is how long before a file's URL expires that it is treated as expired, to leave time
+// for the file to be downloaded.
+const expiryMargin = 5 * time.Minute
+
+// filesExpired reports whether the URL of any file hosted by Notion in the blocks expires before now plus expiryMargin.
+func filesExpired(results *ResultsWrapper, now time.Time) bool {
+	for _, block := range results.Results {
+		for _, file := range []*File{block.Image, block.File, block.PDF} {
+			if file == nil || file.File == nil {
+				continue
+			}
+			expiry, err := time.Parse(time.RFC3339, file.File.ExpiryTime)
+			if err == nil && expiry.Before(now.Add(expiryMargin)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetNotionPage fetches a page and caches it. The page is what tells when it was last edited, so it
+// is always fetched online. Offline, it returns the page as it was last cached.
+func (c *CachingClient) GetNotionPage(pageID, bearerToken string) (*Page, error) {
+	if c.Offline {
+		var page Page
+		if _, ok := c.load(cachePages, pageID, &page); !ok {
+			return nil, c.notCached("page", pageID)
+		}
+		return &page, nil
+	}
+	page, err := c.API.GetNotionPage(pageID, bearerToken)
+	if err != nil {
+		return nil, err
+	}
+	c.store(cachePages, pageID, page.LastEditedTime, page)
+	return page, nil
+}
+
+// Search is not cached, as there is no edit time to tell whether the results are still current.
+func (c *CachingClient) Search(request SearchRequest, bearerToken string) (*SearchResponse, error) {
+	if c.Offline {
+		return nil, fmt.Errorf("search: %w", ErrNotCached)
+	}
+	return c.API.Search(request, bearerToken)
+}
+
+func (c *CachingClient) GetBotUser(bearerToken string) (*User, error) {
+	if c.Offline {
+		return nil, fmt.Errorf("bot user: %w", ErrNotCached)
+	}
+	return c.API.GetBotUser(bearerToken)
+}
+
+func (c *CachingClient) AppendBlockChildren(blockID string, children []Block, bearerToken string) error {
+	if c.Offline {
+		return errors.New("cannot write to Notion offline")
+	}
+	return c.API.AppendBlockChildren(blockID, children, bearerToken)
+}
+
+func (c *CachingClient) DeleteBlock(blockID, bearerToken string) error {
+	if c.Offline {
+		return errors.New("cannot write to Notion offline")
+	}
+	return c.API.DeleteBlock(blockID, bearerToken)
+}
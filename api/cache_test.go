@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cachedPageServer serves a single page, and counts the requests for each path.
+type cachedPageServer struct {
+	lastEdited string
+	paragraph  string
+	requests   map[string]int
+}
+
+func (s *cachedPageServer) client() *NotionApiClient {
+	s.requests = make(map[string]int)
+	return NewNotionApiClient(&MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			path := strings.TrimPrefix(req.URL.Path, "/v1/")
+			s.requests[path]++
+			var body string
+			switch path {
+			case "blocks/page-id":
+				body = fmt.Sprintf(`{"id": "page-id", "type": "child_page", "last_edited_time": %q, "child_page": {"title": "Cached"}}`, s.lastEdited)
+			case "blocks/page-id/children":
+				body = fmt.Sprintf(`{"results": [{"id": "block-id", "type": "paragraph", "paragraph": {"rich_text": [{"plain_text": %q}]}}]}`, s.paragraph)
+			case "pages/page-id":
+				body = fmt.Sprintf(`{"object": "page", "id": "page-id", "last_edited_time": %q}`, s.lastEdited)
+			default:
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{"code": "object_not_found"}`))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		},
+	})
+}
+
+func paragraphText(t *testing.T, results *ResultsWrapper) string {
+	t.Helper()
+	if len(results.Results) != 1 || results.Results[0].Paragraph == nil {
+		t.Fatalf("Expected a single paragraph, got %+v", results.Results)
+	}
+	return plainTextOf(results.Results[0].Paragraph.RichText)
+}
+
+func plainTextOf(richText []RichText) string {
+	var sb strings.Builder
+	for _, rt := range richText {
+		sb.WriteString(rt.PlainText)
+	}
+	return sb.String()
+}
+
+func TestCachingClient(t *testing.T) {
+	dir := t.TempDir()
+	server := &cachedPageServer{lastEdited: "2024-05-01T10:00:00.000Z", paragraph: "first"}
+	client := NewCachingClient(server.client(), dir, false)
+
+	tests := []struct {
+		name     string
+		edit     func()
+		expected string
+		fetched  int
+	}{
+		{"not cached yet", func() {}, "first", 1},
+		{"not edited since", func() { server.paragraph = "changed without an edit time" }, "first", 0},
+		{"edited since", func() { server.lastEdited = "2024-05-02T10:00:00.000Z" }, "changed without an edit time", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.edit()
+			server.requests = make(map[string]int)
+			results, err := client.GetNotionChildBlocks("page-id", "token")
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if text := paragraphText(t, results); text != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, text)
+			}
+			if fetched := server.requests["blocks/page-id/children"]; fetched != tt.fetched {
+				t.Errorf("Expected the children to be fetched %d times, got %d", tt.fetched, fetched)
+			}
+		})
+	}
+}
+
+func TestCachingClientOffline(t *testing.T) {
+	dir := t.TempDir()
+	server := &cachedPageServer{lastEdited: "2024-05-01T10:00:00.000Z", paragraph: "cached"}
+	online := NewCachingClient(server.client(), dir, false)
+	if _, err := online.GetNotionChildBlocks("page-id", "token"); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if _, err := online.GetNotionPage("page-id", "token"); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	server.requests = make(map[string]int)
+	offline := NewCachingClient(server.client(), dir, true)
+	results, err := offline.GetNotionChildBlocks("page-id", "token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if text := paragraphText(t, results); text != "cached" {
+		t.Errorf("Expected the cached paragraph, got %q", text)
+	}
+	page, err := offline.GetNotionPage("page-id", "token")
+	if err != nil || page.LastEditedTime != server.lastEdited {
+		t.Errorf("Expected the cached page, got %+v, %v", page, err)
+	}
+	// IDs are compared in their compact form
+	if title, err := offline.GetNotionBlockTitle("pageid", "token"); err != nil || title != "Cached" {
+		t.Errorf("Expected the cached title, got %q, %v", title, err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("Expected no requests offline, got %v", server.requests)
+	}
+
+	if _, err := offline.GetNotionChildBlocks("other-page", "token"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Expected ErrNotCached for a page that was never fetched, got %v", err)
+	}
+	if _, err := offline.Search(SearchRequest{}, "token"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Expected ErrNotCached for a search, got %v", err)
+	}
+}
+
+func TestCachingClientRefetchesExpiredFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		expiry  string
+		fetched int
+	}{
+		{"valid for a while yet", time.Now().Add(time.Hour).UTC().Format(time.RFC3339), 0},
+		{"expired", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), 1},
+		{"about to expire", time.Now().Add(time.Minute).UTC().Format(time.RFC3339), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			apiClient := NewNotionApiClient(&MockHTTPClient{
+				MockDo: func(req *http.Request) (*http.Response, error) {
+					body := `{"id": "page-id", "type": "child_page", "last_edited_time": "2024-05-01T10:00:00.000Z", "child_page": {"title": "Files"}}`
+					if strings.HasSuffix(req.URL.Path, "/children") {
+						fetches++
+						body = fmt.Sprintf(`{"results": [{"id": "image-id", "type": "image", "image": {"type": "file", "file": {"url": "https://files.example.com/a.png", "expiry_time": %q}}}]}`, tt.expiry)
+					}
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+				},
+			})
+			client := NewCachingClient(apiClient, t.TempDir(), false)
+			if _, err := client.GetNotionChildBlocks("page-id", "token"); err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+
+			fetches = 0
+			if _, err := client.GetNotionChildBlocks("page-id", "token"); err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if fetches != tt.fetched {
+				t.Errorf("Expected the children to be fetched %d times, got %d", tt.fetched, fetches)
+			}
+		})
+	}
+}
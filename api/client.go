@@ -1,34 +1,62 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strings"
 )
 
 type HttpClientInterface interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// DefaultBaseURL is the address of the Notion API.
+const DefaultBaseURL = "https://api.notion.com/v1"
+
 // NotionApiClient struct holds any dependencies for your API client, e.g., the HTTP client.
 type NotionApiClient struct {
 	Client HttpClientInterface
+	// BaseURL is the address requests are sent to, DefaultBaseURL unless the client is pointed
+	// at another server, such as a fake one in tests.
+	BaseURL string
 }
 
 // NewNotionApiClient creates a new API client with the provided HTTP client.
 func NewNotionApiClient(client HttpClientInterface) *NotionApiClient {
 	return &NotionApiClient{
-		Client: client,
+		Client:  client,
+		BaseURL: DefaultBaseURL,
 	}
 }
 
+// url joins an API path, such as /pages/ID, to the base URL.
+func (api *NotionApiClient) url(path string, args ...any) string {
+	base := api.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + fmt.Sprintf(path, args...)
+}
+
 // NotionAPI defines the interface for interacting with the Notion API.
 type NotionAPI interface {
 	GetNotionBlockTitle(blockID, bearerToken string) (string, error)
 	GetNotionChildBlocks(blockID, bearerToken string) (*ResultsWrapper, error)
+	GetNotionPage(pageID, bearerToken string) (*Page, error)
+	GetNotionBlock(blockID, bearerToken string) (*Block, error)
+	Search(request SearchRequest, bearerToken string) (*SearchResponse, error)
+	GetBotUser(bearerToken string) (*User, error)
+	AppendBlockChildren(blockID string, children []Block, bearerToken string) error
+	DeleteBlock(blockID, bearerToken string) error
 }
 
+// MaxAppendBlocks is the most blocks Notion accepts in a single append request.
+const MaxAppendBlocks = 100
+
 var _ NotionAPI = (*NotionApiClient)(nil)
 
 func FetchBlockTitle(apiClient NotionAPI, pageID, bearerToken string) (string, error) {
@@ -39,9 +67,43 @@ func FetchChildBlocks(apiClient NotionAPI, blockID, bearerToken string) (*Result
 	return apiClient.GetNotionChildBlocks(blockID, bearerToken)
 }
 
+func FetchPage(apiClient NotionAPI, pageID, bearerToken string) (*Page, error) {
+	return apiClient.GetNotionPage(pageID, bearerToken)
+}
+
+func FetchBlock(apiClient NotionAPI, blockID, bearerToken string) (*Block, error) {
+	return apiClient.GetNotionBlock(blockID, bearerToken)
+}
+
+func FetchBotUser(apiClient NotionAPI, bearerToken string) (*User, error) {
+	return apiClient.GetBotUser(bearerToken)
+}
+
+// SearchAll runs a search and follows the pagination cursor until every result has been read.
+// objectType is page or database to limit the results to one kind of object, or empty for both.
+func SearchAll(apiClient NotionAPI, query, objectType, bearerToken string) ([]Page, error) {
+	request := SearchRequest{Query: query, PageSize: 100}
+	if objectType != "" {
+		request.Filter = &SearchFilter{Property: "object", Value: objectType}
+	}
+
+	var results []Page
+	for {
+		resp, err := apiClient.Search(request, bearerToken)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			return results, nil
+		}
+		request.StartCursor = resp.NextCursor
+	}
+}
+
 // GetNotionBlockTitle makes an API request to Notion to get the title of a block by its ID.
 func (api *NotionApiClient) GetNotionBlockTitle(blockID, bearerToken string) (string, error) {
-	url := fmt.Sprintf("https://api.notion.com/v1/blocks/%s", blockID)
+	url := api.url("/blocks/%s", blockID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -58,7 +120,7 @@ func (api *NotionApiClient) GetNotionBlockTitle(blockID, bearerToken string) (st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
+		return "", errorResponse(resp)
 	}
 
 	var blockTitleResponse BlockTitleResponse
@@ -69,8 +131,30 @@ func (api *NotionApiClient) GetNotionBlockTitle(blockID, bearerToken string) (st
 	return blockTitleResponse.ChildPage.Title, nil
 }
 
+// GetNotionChildBlocks retrieves every child block of a block, following the pagination cursor
+// for blocks with more than a single page of children.
 func (api *NotionApiClient) GetNotionChildBlocks(blockID, bearerToken string) (*ResultsWrapper, error) {
-	url := fmt.Sprintf("https://api.notion.com/v1/blocks/%s/children?page_size=100", blockID)
+	all := &ResultsWrapper{Results: []Block{}}
+	cursor := ""
+	for {
+		results, err := api.getChildBlocksPage(blockID, cursor, bearerToken)
+		if err != nil {
+			return nil, err
+		}
+		all.Results = append(all.Results, results.Results...)
+		if !results.HasMore || results.NextCursor == "" {
+			return all, nil
+		}
+		cursor = results.NextCursor
+	}
+}
+
+// getChildBlocksPage retrieves a single page of a block's children, starting at cursor unless it is empty.
+func (api *NotionApiClient) getChildBlocksPage(blockID, cursor, bearerToken string) (*ResultsWrapper, error) {
+	url := api.url("/blocks/%s/children?page_size=100", blockID)
+	if cursor != "" {
+		url += "&start_cursor=" + neturl.QueryEscape(cursor)
+	}
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -87,11 +171,7 @@ func (api *NotionApiClient) GetNotionChildBlocks(blockID, bearerToken string) (*
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		var apiError APIErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&apiError); err != nil {
-			return nil, fmt.Errorf("error parsing API error response: %w", err)
-		}
-		return nil, fmt.Errorf("API Error: %s - %s", apiError.Code, apiError.Message)
+		return nil, errorResponse(resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -106,3 +186,233 @@ func (api *NotionApiClient) GetNotionChildBlocks(blockID, bearerToken string) (*
 
 	return &results, nil
 }
+
+// GetNotionPage retrieves a page object, including its properties.
+func (api *NotionApiClient) GetNotionPage(pageID, bearerToken string) (*Page, error) {
+	url := api.url("/pages/%s", pageID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+bearerToken)
+	req.Header.Add("Notion-Version", "2022-06-28")
+
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, errorResponse(resp)
+	}
+
+	var page Page
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return &page, nil
+}
+
+// GetNotionBlock retrieves a single block. Pages and databases are blocks too, so this
+// also works with their IDs, and is how a page's parent is found.
+func (api *NotionApiClient) GetNotionBlock(blockID, bearerToken string) (*Block, error) {
+	url := api.url("/blocks/%s", blockID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+bearerToken)
+	req.Header.Add("Notion-Version", "2022-06-28")
+
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, errorResponse(resp)
+	}
+
+	var block Block
+	if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return &block, nil
+}
+
+// Search returns one page of the pages and databases shared with the integration that match the request.
+func (api *NotionApiClient) Search(request SearchRequest, bearerToken string) (*SearchResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", api.url("/search"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+bearerToken)
+	req.Header.Add("Notion-Version", "2022-06-28")
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, errorResponse(resp)
+	}
+
+	var results SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return &results, nil
+}
+
+// GetBotUser retrieves the bot user the token belongs to, which tells whether the token is valid.
+func (api *NotionApiClient) GetBotUser(bearerToken string) (*User, error) {
+	req, err := http.NewRequest("GET", api.url("/users/me"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+bearerToken)
+	req.Header.Add("Notion-Version", "2022-06-28")
+
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, errorResponse(resp)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	return &user, nil
+}
+
+// AppendBlockChildren adds blocks to the end of a page or block, MaxAppendBlocks at a time.
+func (api *NotionApiClient) AppendBlockChildren(blockID string, children []Block, bearerToken string) error {
+	url := api.url("/blocks/%s/children", blockID)
+
+	for start := 0; start < len(children); start += MaxAppendBlocks {
+		batch := children[start:min(start+MaxAppendBlocks, len(children))]
+		requestBlocks := make([]map[string]any, 0, len(batch))
+		for _, block := range batch {
+			requestBlock, err := newRequestBlock(block)
+			if err != nil {
+				return err
+			}
+			requestBlocks = append(requestBlocks, requestBlock)
+		}
+		body, err := json.Marshal(map[string]any{"children": requestBlocks})
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+
+		req, err := http.NewRequest("PATCH", url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("error creating request: %w", err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+bearerToken)
+		req.Header.Add("Notion-Version", "2022-06-28")
+		req.Header.Add("Content-Type", "application/json")
+
+		if err := api.send(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBlock moves a block to the trash.
+func (api *NotionApiClient) DeleteBlock(blockID, bearerToken string) error {
+	url := api.url("/blocks/%s", blockID)
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+bearerToken)
+	req.Header.Add("Notion-Version", "2022-06-28")
+
+	return api.send(req)
+}
+
+// send sends a request whose response body is not needed, turning an error response into an error.
+func (api *NotionApiClient) send(req *http.Request) error {
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errorResponse(resp)
+	}
+	return nil
+}
+
+// errorResponse reads the error a failed request returned. A body that is not an API error, such
+// as the page a proxy returns, is reported by its status code.
+func errorResponse(resp *http.Response) error {
+	apiError := &APIErrorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(apiError); err != nil || apiError.Code == "" {
+		apiError = &APIErrorResponse{Message: http.StatusText(resp.StatusCode)}
+	}
+	apiError.Status = resp.StatusCode
+	return apiError
+}
+
+// newRequestBlock turns a block into the form the API accepts when creating it: just its type and
+// content, without the fields that are only ever returned, like its ID or the plain text of its rich text.
+func newRequestBlock(block Block) (map[string]any, error) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding block: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error encoding block: %w", err)
+	}
+	content := fields[block.Type]
+	removeReadOnly(content)
+	return map[string]any{"object": "block", "type": block.Type, block.Type: content}, nil
+}
+
+// removeReadOnly removes the rich text fields that the API returns but does not accept.
+func removeReadOnly(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		delete(v, "plain_text")
+		delete(v, "href")
+		for _, child := range v {
+			removeReadOnly(child)
+		}
+	case []any:
+		for _, child := range v {
+			removeReadOnly(child)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"testing"
 )
 
@@ -130,3 +133,392 @@ func TestGetNotionChildBlocks(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNotionChildBlocksPagination(t *testing.T) {
+	var urls []string
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			urls = append(urls, req.URL.String())
+			body := `{"results":[{"id":"a","type":"divider","divider":{}}],"has_more":true,"next_cursor":"b/c"}`
+			if req.URL.Query().Get("start_cursor") != "" {
+				body = `{"results":[{"id":"b/c","type":"divider","divider":{}}],"has_more":false,"next_cursor":null}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		},
+	}
+
+	client := NewNotionApiClient(mockClient)
+	client.BaseURL = "http://localhost:8080/v1/"
+
+	results, err := client.GetNotionChildBlocks("block", "test-bearer-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(results.Results) != 2 || results.Results[1].ID != "b/c" || results.HasMore {
+		t.Errorf("Expected both pages of children, got %+v", results)
+	}
+	expected := []string{
+		"http://localhost:8080/v1/blocks/block/children?page_size=100",
+		"http://localhost:8080/v1/blocks/block/children?page_size=100&start_cursor=b%2Fc",
+	}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, urls)
+	}
+}
+
+func TestGetNotionPage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockResponse   string
+		mockStatusCode int
+		mockErr        error
+		expectedTitle  string
+		expectedTags   []string
+		expectErr      bool
+	}{
+		{
+			name:           "Successful Fetch",
+			mockResponse:   `{"object":"page","id":"abc","properties":{"Name":{"id":"title","type":"title","title":[{"type":"text","plain_text":"Meeting "},{"type":"text","plain_text":"Notes"}]},"Tags":{"id":"t","type":"multi_select","multi_select":[{"name":"work"},{"name":"weekly"}]}}}`,
+			mockStatusCode: http.StatusOK,
+			expectedTitle:  "Meeting Notes",
+			expectedTags:   []string{"work", "weekly"},
+			expectErr:      false,
+		},
+		{
+			name:           "API Error",
+			mockResponse:   `{"object":"error","status":404,"code":"object_not_found","message":"Could not find page"}`,
+			mockStatusCode: http.StatusNotFound,
+			expectErr:      true,
+		},
+		{
+			name:      "HTTP Client Error",
+			mockErr:   fmt.Errorf("network error"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			responseBody := io.NopCloser(bytes.NewReader([]byte(tc.mockResponse)))
+			mockClient := &MockHTTPClient{
+				MockDo: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: tc.mockStatusCode,
+						Body:       responseBody,
+					}, tc.mockErr
+				},
+			}
+			client := NewNotionApiClient(mockClient)
+			page, err := FetchPage(client, "test-page-id", "test-bearer-token")
+
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+
+			if page.Title() != tc.expectedTitle {
+				t.Errorf("Expected title %s, got %s", tc.expectedTitle, page.Title())
+			}
+			tags := page.Properties["Tags"].MultiSelect
+			if len(tags) != len(tc.expectedTags) {
+				t.Fatalf("Expected %d tags, got %d", len(tc.expectedTags), len(tags))
+			}
+			for i, tag := range tags {
+				if tag.Name != tc.expectedTags[i] {
+					t.Errorf("Expected tag %s, got %s", tc.expectedTags[i], tag.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetNotionBlock(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockResponse   string
+		mockStatusCode int
+		mockErr        error
+		expectedParent string
+		expectErr      bool
+	}{
+		{
+			name:           "Successful Fetch",
+			mockResponse:   `{"object":"block","id":"abc","type":"child_page","parent":{"type":"page_id","page_id":"def"},"child_page":{"title":"Child"}}`,
+			mockStatusCode: http.StatusOK,
+			expectedParent: "def",
+			expectErr:      false,
+		},
+		{
+			name:           "Workspace Parent",
+			mockResponse:   `{"object":"block","id":"abc","type":"child_page","parent":{"type":"workspace","workspace":true},"child_page":{"title":"Top"}}`,
+			mockStatusCode: http.StatusOK,
+			expectedParent: "",
+			expectErr:      false,
+		},
+		{
+			name:           "API Error",
+			mockResponse:   `{"object":"error","status":404,"code":"object_not_found","message":"Could not find block"}`,
+			mockStatusCode: http.StatusNotFound,
+			expectErr:      true,
+		},
+		{
+			name:      "HTTP Client Error",
+			mockErr:   fmt.Errorf("network error"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			responseBody := io.NopCloser(bytes.NewReader([]byte(tc.mockResponse)))
+			mockClient := &MockHTTPClient{
+				MockDo: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: tc.mockStatusCode,
+						Body:       responseBody,
+					}, tc.mockErr
+				},
+			}
+			client := NewNotionApiClient(mockClient)
+			block, err := FetchBlock(client, "test-block-id", "test-bearer-token")
+
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+
+			if block.Parent == nil {
+				t.Fatalf("Expected the block to have a parent")
+			}
+			if block.Parent.ID() != tc.expectedParent {
+				t.Errorf("Expected parent %q, got %q", tc.expectedParent, block.Parent.ID())
+			}
+		})
+	}
+}
+
+func TestNormalizeID(t *testing.T) {
+	if got := NormalizeID("1A2b3c4d-0000-1111-2222-333344445555"); got != "1a2b3c4d000011112222333344445555" {
+		t.Errorf("NormalizeID() = %q", got)
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	var requests []SearchRequest
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			var request SearchRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				t.Fatalf("Failed to decode request body: %v", err)
+			}
+			requests = append(requests, request)
+
+			body := `{"results":[{"object":"page","id":"a"}],"has_more":true,"next_cursor":"cursor-1"}`
+			if request.StartCursor == "cursor-1" {
+				body = `{"results":[{"object":"database","id":"b","title":[{"plain_text":"Tasks"}]}],"has_more":false,"next_cursor":null}`
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+			}, nil
+		},
+	}
+
+	client := NewNotionApiClient(mockClient)
+	results, err := SearchAll(client, "notes", "page", "test-bearer-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].Query != "notes" || requests[0].Filter == nil || requests[0].Filter.Value != "page" {
+		t.Errorf("Expected the query and filter to be sent, got %+v", requests[0])
+	}
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Fatalf("Expected results from both pages, got %+v", results)
+	}
+	if results[1].Title() != "Tasks" {
+		t.Errorf("Expected the database title Tasks, got %q", results[1].Title())
+	}
+}
+
+func TestSearchDecodesDatabases(t *testing.T) {
+	// A database as the search endpoint returns it, with its property schema rather than values
+	database := `{
+		"object": "database",
+		"id": "bc1211ca-e3f1-4939-ae34-5260b16f627c",
+		"created_time": "2021-07-08T23:50:00.000Z",
+		"last_edited_time": "2021-07-08T23:50:00.000Z",
+		"title": [{"type": "text", "text": {"content": "Grocery List", "link": null}, "annotations": {"bold": false, "italic": false, "strikethrough": false, "underline": false, "code": false, "color": "default"}, "plain_text": "Grocery List", "href": null}],
+		"description": [],
+		"icon": {"type": "emoji", "emoji": "🎉"},
+		"cover": null,
+		"properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+			"Store availability": {"id": "flsb", "name": "Store availability", "type": "multi_select", "multi_select": {"options": [{"id": "5de29601-9c24-4b04-8629-0bca891c5120", "name": "Duc Loi Market", "color": "blue"}]}},
+			"Price": {"id": "evWq", "name": "Price", "type": "number", "number": {"format": "dollar"}}
+		},
+		"parent": {"type": "page_id", "page_id": "98ad959b-2b6a-4774-80ee-00246fb0ea9b"},
+		"url": "https://www.notion.so/bc1211cae3f14939ae345260b16f627c",
+		"archived": false,
+		"is_inline": false
+	}`
+	page := `{
+		"object": "page",
+		"id": "59833787-2cf9-4fdf-8782-e53db20768a5",
+		"properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Tuscan kale"}, "plain_text": "Tuscan kale"}]},
+			"Store availability": {"id": "flsb", "type": "multi_select", "multi_select": [{"id": "5de29601-9c24-4b04-8629-0bca891c5120", "name": "Duc Loi Market", "color": "blue"}]}
+		},
+		"parent": {"type": "database_id", "database_id": "bc1211ca-e3f1-4939-ae34-5260b16f627c"}
+	}`
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			body := `{"object": "list", "results": [` + database + `, ` + page + `], "has_more": false, "next_cursor": null}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte(body)))}, nil
+		},
+	}
+
+	results, err := NewNotionApiClient(mockClient).Search(SearchRequest{}, "test-bearer-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(results.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results.Results))
+	}
+	if db := results.Results[0]; db.Title() != "Grocery List" || db.Properties["Store availability"].Type != "multi_select" || db.Properties["Store availability"].MultiSelect != nil {
+		t.Errorf("Expected the database with its title and property types, got %+v", db)
+	}
+	if entry := results.Results[1]; entry.Title() != "Tuscan kale" || len(entry.Properties["Store availability"].MultiSelect) != 1 {
+		t.Errorf("Expected the page with its property values, got %+v", entry)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{"object":"error","status":401,"code":"unauthorized","message":"API token is invalid."}`))),
+			}, nil
+		},
+	}
+
+	client := NewNotionApiClient(mockClient)
+	if _, err := SearchAll(client, "", "", "test-bearer-token"); err == nil {
+		t.Errorf("Expected an error but did not get one")
+	}
+}
+
+func TestAPIErrorIsTyped(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		statusCode   int
+		expectedCode string
+		expectedText string
+	}{
+		{"API error", `{"object":"error","status":404,"code":"object_not_found","message":"Could not find block."}`, http.StatusNotFound, "object_not_found", "API Error: object_not_found - Could not find block."},
+		{"proxy error page", `<html>Bad Gateway</html>`, http.StatusBadGateway, "", "API Error: status 502 - Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &MockHTTPClient{
+				MockDo: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: tt.statusCode, Body: io.NopCloser(bytes.NewReader([]byte(tt.body)))}, nil
+				},
+			}
+			_, err := NewNotionApiClient(mockClient).GetNotionChildBlocks("block", "test-bearer-token")
+
+			var apiError *APIErrorResponse
+			if !errors.As(err, &apiError) {
+				t.Fatalf("Expected an *APIErrorResponse, got %v", err)
+			}
+			if apiError.Status != tt.statusCode || apiError.Code != tt.expectedCode || err.Error() != tt.expectedText {
+				t.Errorf("Unexpected error %+v: %v", apiError, err)
+			}
+		})
+	}
+}
+
+func TestGetBotUser(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() != "https://api.notion.com/v1/users/me" {
+				t.Errorf("Unexpected URL %s", req.URL)
+			}
+			body := `{"object":"user","id":"u1","name":"Sync","type":"bot","bot":{"workspace_name":"Acme"}}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+			}, nil
+		},
+	}
+
+	user, err := NewNotionApiClient(mockClient).GetBotUser("test-bearer-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if user.Name != "Sync" || user.Bot == nil || user.Bot.WorkspaceName != "Acme" {
+		t.Errorf("Unexpected user %+v", user)
+	}
+}
+
+func TestAppendBlockChildren(t *testing.T) {
+	var batches []int
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			if req.Method != "PATCH" || req.URL.Path != "/v1/blocks/page-1/children" {
+				t.Errorf("Unexpected request %s %s", req.Method, req.URL)
+			}
+			var body struct {
+				Children []map[string]any `json:"children"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode request body: %v", err)
+			}
+			batches = append(batches, len(body.Children))
+
+			first := body.Children[0]
+			if _, ok := first["id"]; ok {
+				t.Errorf("Expected the block ID to be left out, got %v", first)
+			}
+			text := first["paragraph"].(map[string]any)["rich_text"].([]any)[0].(map[string]any)
+			if _, ok := text["plain_text"]; ok {
+				t.Errorf("Expected plain_text to be left out, got %v", text)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{"results":[]}`))),
+			}, nil
+		},
+	}
+
+	var blocks []Block
+	for i := 0; i < MaxAppendBlocks+20; i++ {
+		blocks = append(blocks, Block{ID: "b", Type: "paragraph", Paragraph: &Paragraph{
+			RichText: []RichText{{Type: "text", Text: Text{Content: "text"}, PlainText: "text"}},
+		}})
+	}
+
+	err := NewNotionApiClient(mockClient).AppendBlockChildren("page-1", blocks, "test-bearer-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(batches) != 2 || batches[0] != MaxAppendBlocks || batches[1] != 20 {
+		t.Errorf("Expected batches of %d and 20 blocks, got %v", MaxAppendBlocks, batches)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LoggingClient logs every request at debug level: its method, URL, status, how long it took and
+// the request ID Notion gave it. Headers are never logged, so the token stays out of the log.
+type LoggingClient struct {
+	Client HttpClientInterface
+}
+
+var _ HttpClientInterface = (*LoggingClient)(nil)
+
+// NewLoggingClient wraps client so that its requests are logged.
+func NewLoggingClient(client HttpClientInterface) *LoggingClient {
+	return &LoggingClient{Client: client}
+}
+
+func (c *LoggingClient) Do(req *http.Request) (*http.Response, error) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return c.Client.Do(req)
+	}
+	start := time.Now()
+	resp, err := c.Client.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		slog.Debug("request failed", "method", req.Method, "url", req.URL.String(), "duration", duration, "err", err)
+		return resp, err
+	}
+	slog.Debug("request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode,
+		"duration", duration, "request_id", resp.Header.Get("X-Request-Id"))
+	return resp, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoggingClient(t *testing.T) {
+	var out bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			if strings.Contains(req.URL.Path, "broken") {
+				return nil, errors.New("connection reset")
+			}
+			header := http.Header{}
+			header.Set("X-Request-Id", "req-123")
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		},
+	}
+	client := NewLoggingClient(mockClient)
+
+	req, _ := http.NewRequest("GET", "https://api.notion.com/v1/blocks/abc", nil)
+	req.Header.Add("Authorization", "Bearer test-bearer-token")
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	broken, _ := http.NewRequest("GET", "https://api.notion.com/v1/broken", nil)
+	if _, err := client.Do(broken); err == nil {
+		t.Fatalf("Expected the error to be passed on")
+	}
+
+	logged := out.String()
+	for _, want := range []string{"method=GET", "url=https://api.notion.com/v1/blocks/abc", "status=200", "request_id=req-123", "duration=", `msg="request failed"`, `err="connection reset"`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("Expected the log to contain %q, got:\n%s", want, logged)
+		}
+	}
+	if strings.Contains(logged, "test-bearer-token") {
+		t.Errorf("Expected the token to stay out of the log, got:\n%s", logged)
+	}
+}
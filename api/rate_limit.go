@@ -0,0 +1,95 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/progress"
+)
+
+// DefaultRequestsPerSecond is the average request rate Notion allows for an integration.
+const DefaultRequestsPerSecond = 3
+
+// RateLimitedClient spaces out requests to stay under Notion's rate limit, and retries
+// requests that are rejected with 429 Too Many Requests once the Retry-After delay has passed.
+// It is safe for concurrent use, so one client can be shared by every worker.
+type RateLimitedClient struct {
+	Client     HttpClientInterface
+	Interval   time.Duration
+	MaxRetries int
+	// Events receives an event for every request sent and every wait after a 429 response.
+	Events progress.Listener
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+var _ HttpClientInterface = (*RateLimitedClient)(nil)
+
+// NewRateLimitedClient wraps client so that it sends at most requestsPerSecond requests on average.
+func NewRateLimitedClient(client HttpClientInterface, requestsPerSecond float64) *RateLimitedClient {
+	return &RateLimitedClient{
+		Client:     client,
+		Interval:   time.Duration(float64(time.Second) / requestsPerSecond),
+		MaxRetries: 5,
+	}
+}
+
+func (c *RateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		c.wait()
+		c.Events.Emit(progress.Event{Kind: progress.Request})
+		resp, err := c.Client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= c.MaxRetries {
+			return resp, err
+		}
+		resp.Body.Close()
+		wait := retryAfter(resp)
+		slog.Info("rate limited by Notion, waiting before retrying", "delay", wait, "attempt", attempt+1)
+		c.Events.Emit(progress.Event{Kind: progress.RateLimited, Wait: wait})
+		c.delay(wait)
+
+		// Requests with a body need a fresh copy of it for the retry
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// wait blocks until the next request is allowed to be sent.
+func (c *RateLimitedClient) wait() {
+	c.mu.Lock()
+	start := time.Now()
+	if c.next.After(start) {
+		start = c.next
+	}
+	c.next = start.Add(c.Interval)
+	c.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+}
+
+// delay holds back every request until d has passed.
+func (c *RateLimitedClient) delay(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until := time.Now().Add(d); until.After(c.next) {
+		c.next = until
+	}
+}
+
+// retryAfter reads the number of seconds to wait from a 429 response, defaulting to one second.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
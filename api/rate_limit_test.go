@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/progress"
+)
+
+func TestRateLimitedClientRetriesTooManyRequests(t *testing.T) {
+	calls := 0
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			calls++
+			status := http.StatusOK
+			if calls < 3 {
+				status = http.StatusTooManyRequests
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Retry-After": []string{"0"}},
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	}
+
+	display := progress.NewDisplay(nil, false)
+	client := NewRateLimitedClient(mockClient, 1000)
+	client.Events = display.Handle
+	req, _ := http.NewRequest("GET", "https://api.notion.com/v1/blocks/test", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+	if counts := display.Counts(); counts.Requests != 3 || counts.RateLimited != 2 {
+		t.Errorf("Expected 3 requests and 2 rate limit waits, got %+v", counts)
+	}
+}
+
+func TestRateLimitedClientGivesUp(t *testing.T) {
+	calls := 0
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": []string{"0"}},
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	}
+
+	client := NewRateLimitedClient(mockClient, 1000)
+	client.MaxRetries = 2
+	req, _ := http.NewRequest("GET", "https://api.notion.com/v1/blocks/test", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the final 429 to be returned, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRateLimitedClientSpacesRequests(t *testing.T) {
+	mockClient := &MockHTTPClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		},
+	}
+
+	client := NewRateLimitedClient(mockClient, 50)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest("GET", "https://api.notion.com/v1/blocks/test", nil)
+		if _, err := client.Do(req); err != nil {
+			t.Fatalf("Did not expect an error but got one: %v", err)
+		}
+	}
+	// The first request goes straight away, the other three wait 20ms each
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected requests to be spaced out over at least 60ms, took %v", elapsed)
+	}
+}
@@ -1,5 +1,11 @@
 package api
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 type APIErrorResponse struct {
 	Object  string `json:"object,omitempty"`
 	Status  int    `json:"status,omitempty"`
@@ -7,9 +13,20 @@ type APIErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// Error makes a failed request's response an error, which callers can inspect with errors.As.
+func (e *APIErrorResponse) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("API Error: status %d - %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("API Error: %s - %s", e.Code, e.Message)
+}
+
 // ResultsWrapper is the structure of your successful response
 type ResultsWrapper struct {
 	Results []Block `json:"results"`
+	// HasMore and NextCursor are set on a single page of results when there are more to fetch.
+	HasMore    bool   `json:"has_more,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // BlockTitleResponse represents the structure to capture the title from a Notion block API response.
@@ -22,6 +39,7 @@ type BlockTitleResponse struct {
 type Block struct {
 	ID          string      `json:"id"`
 	Type        string      `json:"type"`
+	Parent      *Parent     `json:"parent,omitempty"`
 	HasChildren bool        `json:"has_children"`
 	Heading1    *Heading    `json:"heading_1,omitempty"`
 	Heading2    *Heading    `json:"heading_2,omitempty"`
@@ -36,6 +54,12 @@ type Block struct {
 	ChildPage   *ChildPage  `json:"child_page,omitempty"`
 	LinkToPage  *LinkToPage `json:"link_to_page,omitempty"`
 	Divider     *Divider    `json:"divider,omitempty"`
+	Callout     *Callout    `json:"callout,omitempty"`
+	Image       *File       `json:"image,omitempty"`
+	File        *File       `json:"file,omitempty"`
+	PDF         *File       `json:"pdf,omitempty"`
+	// LastEditedTime is set on blocks returned by the API, and left out of blocks sent to it.
+	LastEditedTime string `json:"last_edited_time,omitempty"`
 }
 
 // Heading represents a generic heading, which can be used for both heading_1, heading_2, heading_3 etc.
@@ -79,6 +103,47 @@ type Todo struct {
 
 type Divider struct{}
 
+type Callout struct {
+	RichText []RichText `json:"rich_text"`
+	Icon     *Icon      `json:"icon,omitempty"`
+	Color    string     `json:"color"`
+}
+
+type Icon struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji,omitempty"`
+}
+
+// File represents the image, file and pdf blocks, which are either hosted by Notion or external.
+type File struct {
+	Type     string        `json:"type"`
+	External *ExternalFile `json:"external,omitempty"`
+	File     *HostedFile   `json:"file,omitempty"`
+	Caption  []RichText    `json:"caption"`
+	Name     string        `json:"name,omitempty"`
+}
+
+type ExternalFile struct {
+	URL string `json:"url"`
+}
+
+// HostedFile is a file uploaded to Notion. Its URL is only valid until ExpiryTime.
+type HostedFile struct {
+	URL        string `json:"url"`
+	ExpiryTime string `json:"expiry_time"`
+}
+
+// URL returns the download URL of the file regardless of where it is hosted.
+func (f *File) URL() string {
+	if f.File != nil {
+		return f.File.URL
+	}
+	if f.External != nil {
+		return f.External.URL
+	}
+	return ""
+}
+
 type LinkObject struct {
 	URL *string `json:"url,omitempty"`
 }
@@ -99,11 +164,172 @@ type Annotations struct {
 type RichText struct {
 	Type        string      `json:"type"`
 	Text        Text        `json:"text"`
+	Mention     *Mention    `json:"mention,omitempty"`
 	Annotations Annotations `json:"annotations"`
 	PlainText   string      `json:"plain_text"`
 	Href        *string     `json:"href,omitempty"`
 }
 
+// Mention is the content of a rich text object of type "mention".
+type Mention struct {
+	Type     string         `json:"type"`
+	Page     *PageReference `json:"page,omitempty"`
+	Database *PageReference `json:"database,omitempty"`
+}
+
+type PageReference struct {
+	ID string `json:"id"`
+}
+
+// Page is the page object returned by the pages endpoint.
+type Page struct {
+	Object         string              `json:"object"`
+	ID             string              `json:"id"`
+	CreatedTime    string              `json:"created_time"`
+	LastEditedTime string              `json:"last_edited_time"`
+	URL            string              `json:"url"`
+	Parent         *Parent             `json:"parent,omitempty"`
+	Properties     map[string]Property `json:"properties"`
+	// DatabaseTitle is the title of a database. Search results include databases as well as pages,
+	// and a database keeps its title here rather than in a property.
+	DatabaseTitle []RichText `json:"title,omitempty"`
+}
+
+// UnmarshalJSON decodes a page or database. The properties of a database are its schema, which
+// holds the configuration of each property rather than a value, so only their IDs and types are decoded.
+func (p *Page) UnmarshalJSON(data []byte) error {
+	type page Page
+	var raw struct {
+		page
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Page(raw.page)
+	if raw.Properties == nil {
+		return nil
+	}
+	p.Properties = make(map[string]Property, len(raw.Properties))
+	for name, data := range raw.Properties {
+		var property Property
+		if p.Object == "database" {
+			var schema struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(data, &schema); err != nil {
+				return err
+			}
+			property = Property{ID: schema.ID, Type: schema.Type}
+		} else if err := json.Unmarshal(data, &property); err != nil {
+			return err
+		}
+		p.Properties[name] = property
+	}
+	return nil
+}
+
+// User is a user object. The token's own user, returned by the users/me endpoint, is a bot.
+type User struct {
+	Object string `json:"object"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Bot    *Bot   `json:"bot,omitempty"`
+}
+
+// Bot holds the details of an integration's bot user.
+type Bot struct {
+	WorkspaceName string `json:"workspace_name"`
+}
+
+// SearchRequest is the body of a search request. Filter limits the results to pages or databases.
+type SearchRequest struct {
+	Query       string        `json:"query,omitempty"`
+	Filter      *SearchFilter `json:"filter,omitempty"`
+	StartCursor string        `json:"start_cursor,omitempty"`
+	PageSize    int           `json:"page_size,omitempty"`
+}
+
+// SearchFilter limits search results by a property of the object, which Notion only allows to be object.
+type SearchFilter struct {
+	Property string `json:"property"`
+	Value    string `json:"value"`
+}
+
+// SearchResponse is one page of search results. When HasMore is set, the next page starts at NextCursor.
+type SearchResponse struct {
+	Results    []Page `json:"results"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// Parent is where a page or block lives. Type is one of page_id, database_id, block_id or workspace,
+// and says which of the ID fields is set.
+type Parent struct {
+	Type       string `json:"type"`
+	PageID     string `json:"page_id,omitempty"`
+	DatabaseID string `json:"database_id,omitempty"`
+	BlockID    string `json:"block_id,omitempty"`
+	Workspace  bool   `json:"workspace,omitempty"`
+}
+
+// ID returns the ID of the parent page, database or block, or an empty string for the workspace.
+func (p *Parent) ID() string {
+	switch p.Type {
+	case "page_id":
+		return p.PageID
+	case "database_id":
+		return p.DatabaseID
+	case "block_id":
+		return p.BlockID
+	}
+	return ""
+}
+
+// NormalizeID returns an ID in the compact form used in page URLs, so that the dashed
+// IDs returned by the API and the IDs taken from URLs can be compared.
+func NormalizeID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
+
+// Property is a page property. Only the fields for the property types we read are decoded.
+type Property struct {
+	ID          string         `json:"id"`
+	Type        string         `json:"type"`
+	Title       []RichText     `json:"title,omitempty"`
+	MultiSelect []SelectOption `json:"multi_select,omitempty"`
+}
+
+type SelectOption struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Title returns the plain text of the page's title property.
+func (p *Page) Title() string {
+	if p.Object == "database" {
+		var title string
+		for _, rt := range p.DatabaseTitle {
+			title += rt.PlainText
+		}
+		return title
+	}
+	for _, property := range p.Properties {
+		if property.Type != "title" {
+			continue
+		}
+		var title string
+		for _, rt := range property.Title {
+			title += rt.PlainText
+		}
+		return title
+	}
+	return ""
+}
+
 // RichTextProvider interface for blocks that contain Rich Text
 type RichTextProvider interface {
 	GetRichText() []RichText
@@ -138,3 +364,8 @@ func (c *Code) GetRichText() []RichText {
 func (q *Quote) GetRichText() []RichText {
 	return q.RichText
 }
+
+// Implement GetRichText for Callout
+func (c *Callout) GetRichText() []RichText {
+	return c.RichText
+}
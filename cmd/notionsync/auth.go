@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/logging"
+	"github.com/s-kngstn/notionsync/pkg/oauth"
+	"github.com/s-kngstn/notionsync/pkg/token"
+	"golang.org/x/term"
+)
+
+var authCommands = []command{
+	{name: "auth login", usage: "[flags]", summary: "Save a token, or sign in through the browser with -oauth, for the workspace it belongs to", run: runAuthLogin},
+	{name: "auth logout", usage: "[name]", summary: "Delete the saved token of a workspace, by default the default one", run: runAuthLogout},
+	{name: "auth list", usage: "", summary: "List the workspaces with a saved token", run: runAuthList},
+}
+
+// runAuth handles `notionsync auth`, checking that Notion accepts the token, and hands
+// `auth login`, `auth logout` and `auth list` to their own commands.
+func runAuth(flags *flag.FlagSet, args []string) error {
+	if len(args) > 0 {
+		for _, sub := range authCommands {
+			if sub.name == "auth "+args[0] {
+				return sub.run(sub.flagSet(), args[1:])
+			}
+		}
+	}
+
+	tokens := addTokenFlags(flags)
+	flags.Usage = authUsage(flags.Usage)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageError(flags, fmt.Sprintf("Unknown auth command %q", flags.Arg(0)))
+	}
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+
+	user, err := api.FetchBotUser(newAPIClient(), bearerToken)
+	if err != nil {
+		return fmt.Errorf("the token was not accepted: %w", err)
+	}
+	fmt.Println(describeUser(user))
+	return nil
+}
+
+// authUsage adds the auth subcommands to the usage of the auth command.
+func authUsage(usage func()) func() {
+	return func() {
+		usage()
+		fmt.Fprintln(os.Stderr, "\nCommands:")
+		for _, sub := range authCommands {
+			fmt.Fprintf(os.Stderr, "  %-12s %s\n", sub.name, sub.summary)
+		}
+	}
+}
+
+func describeUser(user *api.User) string {
+	description := fmt.Sprintf("Authenticated as %s", titleOrUntitled(user.Name))
+	if user.Bot != nil && user.Bot.WorkspaceName != "" {
+		description += fmt.Sprintf(" in workspace %s", user.Bot.WorkspaceName)
+	}
+	return description
+}
+
+// The public integration signed in with by `auth login -oauth`. Release builds set them with
+// -ldflags "-X main.oauthClientID=... -X main.oauthClientSecret=...".
+var (
+	oauthClientID     string
+	oauthClientSecret string
+)
+
+// Environment variables that override the public integration.
+const (
+	clientIDEnv     = "NOTION_OAUTH_CLIENT_ID"
+	clientSecretEnv = "NOTION_OAUTH_CLIENT_SECRET"
+)
+
+// oauthLoginTimeout is how long to wait for the user to finish signing in in the browser.
+const oauthLoginTimeout = 5 * time.Minute
+
+// runAuthLogin handles `notionsync auth login`. The token is read from -token, or from stdin,
+// where it is typed without being shown, or piped in by a script. With -oauth the user signs
+// in through the browser instead.
+func runAuthLogin(flags *flag.FlagSet, args []string) error {
+	tokenFlag := flags.String("token", "", "Token to save, read from stdin when not given")
+	name := flags.String("name", "", "Name to save the token under, by default the name of the workspace")
+	makeDefault := flags.Bool("default", false, "Use this workspace when no login is chosen, as the first one saved is")
+	encrypt := flags.Bool("encrypt", false, "Encrypt the credentials file with a passphrase, read from "+token.PassphraseEnv+" or asked for")
+	useOAuth := flags.Bool("oauth", false, "Sign in through the browser with the public integration instead of giving a token")
+	clientID := flags.String("client-id", "", "With -oauth, the OAuth client ID of the integration, read from "+clientIDEnv+" when not given")
+	clientSecret := flags.String("client-secret", "", "With -oauth, the OAuth client secret of the integration, read from "+clientSecretEnv+" when not given")
+	redirectURL := flags.String("redirect-url", oauth.DefaultRedirectURL, "With -oauth, the redirect URI of the integration to listen on")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *useOAuth {
+		config := oauth.Config{
+			ClientID:     firstNonEmpty(*clientID, os.Getenv(clientIDEnv), oauthClientID),
+			ClientSecret: firstNonEmpty(*clientSecret, os.Getenv(clientSecretEnv), oauthClientSecret),
+			RedirectURL:  *redirectURL,
+			OpenBrowser:  openAuthURL,
+		}
+		credential, err := oauthLogin(config, *name)
+		if err != nil {
+			return err
+		}
+		return saveCredential(credential, *makeDefault, *encrypt)
+	}
+
+	bearerToken := *tokenFlag
+	if bearerToken == "" {
+		var err error
+		if bearerToken, err = readToken(); err != nil {
+			return err
+		}
+	}
+	user, err := api.FetchBotUser(newAPIClient(), bearerToken)
+	if err != nil {
+		return fmt.Errorf("the token was not accepted: %w", err)
+	}
+
+	credential := token.Credential{Name: *name, Token: bearerToken}
+	if user.Bot != nil {
+		credential.WorkspaceName = user.Bot.WorkspaceName
+	}
+	if credential.Name == "" {
+		credential.Name = loginName(credential.WorkspaceName)
+	}
+	return saveCredential(credential, *makeDefault, *encrypt)
+}
+
+// oauthLogin signs in through the browser, and returns the credential to save for the workspace picked.
+func oauthLogin(config oauth.Config, name string) (token.Credential, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return token.Credential{}, fmt.Errorf("no OAuth client configured, pass -client-id and -client-secret or set %s and %s", clientIDEnv, clientSecretEnv)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, oauthLoginTimeout)
+	defer cancel()
+
+	oauthToken, err := config.Login(ctx)
+	if err != nil {
+		return token.Credential{}, err
+	}
+	logging.AddSecret(oauthToken.AccessToken)
+	credential := token.Credential{
+		Name:          name,
+		Token:         oauthToken.AccessToken,
+		WorkspaceID:   oauthToken.WorkspaceID,
+		WorkspaceName: oauthToken.WorkspaceName,
+	}
+	if credential.Name == "" {
+		credential.Name = loginName(credential.WorkspaceName)
+	}
+	return credential, nil
+}
+
+// openAuthURL shows the sign-in URL, in case no browser can be opened, and tries to open it.
+func openAuthURL(authURL string) error {
+	fmt.Fprintf(os.Stderr, "Sign in to Notion in your browser. If it does not open, visit:\n%s\n", authURL)
+	if err := oauth.OpenBrowser(authURL); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not open a browser:", err)
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// saveCredential adds a credential to the credentials file, encrypting the file first if asked to.
+func saveCredential(credential token.Credential, makeDefault, encrypt bool) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if encrypt && !store.Encrypted() {
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		store.SetPassphrase(passphrase)
+	}
+
+	store.Add(credential)
+	if makeDefault {
+		store.SetDefault(credential.Name)
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Saved the token for %s as %q in %s\n", titleOrUntitled(credential.WorkspaceName), credential.Name, store.Path())
+	return nil
+}
+
+// loginName turns a workspace name into the name a login is saved under.
+func loginName(workspaceName string) string {
+	if name := strcase.ToKebab(workspaceName); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// runAuthLogout handles `notionsync auth logout [name]`.
+func runAuthLogout(flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return usageError(flags, "Expected at most one workspace name")
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	name := flags.Arg(0)
+	if name == "" {
+		if name = store.Default(); name == "" {
+			return fmt.Errorf("no default workspace, name the one to log out of, see auth list")
+		}
+	}
+	if err := store.Remove(name); err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted the token saved as %q\n", name)
+	return nil
+}
+
+// runAuthList handles `notionsync auth list`, marking the default workspace with a *.
+func runAuthList(flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	credentials := store.List()
+	if len(credentials) == 0 {
+		fmt.Println("No saved tokens, save one with notionsync auth login")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, credential := range credentials {
+		marker := " "
+		if credential.Name == store.Default() {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", marker, credential.Name, titleOrUntitled(credential.WorkspaceName), credential.SavedAt.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+// savedCredential returns the saved credential for a workspace, or for the default workspace when name is empty.
+func savedCredential(name string) (token.Credential, error) {
+	store, err := openStore()
+	if err != nil {
+		return token.Credential{}, err
+	}
+	return store.Get(name)
+}
+
+// openStore opens the credentials file, asking for its passphrase when it is encrypted and the passphrase is not set in the environment.
+func openStore() (*token.Store, error) {
+	path, err := token.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	store, err := token.Open(path, os.Getenv(token.PassphraseEnv))
+	if !errors.Is(err, token.ErrPassphraseRequired) {
+		return store, err
+	}
+	passphrase, err := readSecret("Passphrase for "+path+": ", fmt.Errorf("%w, set %s", err, token.PassphraseEnv))
+	if err != nil {
+		return nil, err
+	}
+	return token.Open(path, passphrase)
+}
+
+// newPassphrase returns the passphrase to encrypt the credentials file with, asking for it twice when it is not set in the environment.
+func newPassphrase() (string, error) {
+	if passphrase := os.Getenv(token.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	missing := fmt.Errorf("no passphrase to encrypt the credentials file with, set %s", token.PassphraseEnv)
+	passphrase, err := readSecret("New passphrase: ", missing)
+	if err != nil {
+		return "", err
+	}
+	again, err := readSecret("Repeat the passphrase: ", missing)
+	if err != nil {
+		return "", err
+	}
+	if passphrase != again {
+		return "", errors.New("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readToken reads the token to save, hidden as it is typed at a terminal, or piped in on stdin.
+func readToken() (string, error) {
+	missing := errors.New("no token given, pass -token or pipe it in on stdin")
+	if isTerminal(os.Stdin) {
+		return readSecret("Please enter the Notion API bearer token: ", missing)
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("error reading token: %w", err)
+	}
+	if bearerToken := strings.TrimSpace(string(data)); bearerToken != "" {
+		logging.AddSecret(bearerToken)
+		return bearerToken, nil
+	}
+	return "", missing
+}
+
+// readSecret asks for a value without showing it as it is typed. When stdin is not a terminal
+// there is nobody to ask, so it returns the missing error.
+func readSecret(prompt string, missing error) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", missing
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+	if len(secret) == 0 {
+		return "", missing
+	}
+	logging.AddSecret(string(secret))
+	return string(secret), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+)
+
+// runCat handles `notionsync cat <url>`, writing a single page to stdout rather than to a file.
+// Child and linked pages are linked to but not rendered.
+func runCat(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	formatFlags := addFormatFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "Expected the URL or ID of the page to render")
+	}
+	opts, err := formatFlags.options()
+	if err != nil {
+		return err
+	}
+	ref, err := fetch.Resolve(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if ref.Type == fetch.DatabaseRef {
+		return fmt.Errorf("cannot render %s, only a page", ref)
+	}
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+	apiClient := newAPIClient()
+
+	results, err := api.FetchChildBlocks(apiClient, ref.ID, bearerToken)
+	if err != nil {
+		return err
+	}
+
+	// The title comes from the page, since there is no parent block to take it from
+	pageID := ref.ID
+	if ref.Type == fetch.BlockRef {
+		pageID = ref.PageID
+	}
+	notionPage, err := api.FetchPage(apiClient, pageID, bearerToken)
+	if err != nil {
+		return err
+	}
+	page := format.NewPage(ref.Name, notionPage)
+
+	return format.RenderPage(os.Stdout, results, page, apiClient, bearerToken, opts)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/config"
+)
+
+// runDoctor handles `notionsync doctor`, checking everything a pull depends on and reporting each problem found.
+// It never asks for anything, so it can be run from scripts.
+func runDoctor(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	outputDir := flags.String("dir", "notion-notes", "Directory markdown files are saved in")
+	configPath := flags.String("config", "", "Path to the config file to check")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	failures := 0
+	report := func(err error, message string) {
+		if err != nil {
+			failures++
+			fmt.Printf("FAIL  %s: %v\n", message, err)
+			return
+		}
+		fmt.Printf("ok    %s\n", message)
+	}
+
+	path, err := config.Find(*configPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && *configPath == "":
+		fmt.Println("-     No config file found")
+	case err != nil:
+		report(err, "Config file")
+	default:
+		file, err := config.Load(path)
+		report(err, fmt.Sprintf("Config file %s", path))
+		if err == nil {
+			fmt.Printf("      Profiles: %v\n", file.Names())
+		}
+	}
+
+	report(checkWritable(*outputDir), fmt.Sprintf("Output directory %s is writable", *outputDir))
+
+	bearerToken, source, err := tokens.find()
+	if err == nil && bearerToken == "" {
+		err = fmt.Errorf("set %s, pass -token or save one with auth login", config.DefaultTokenEnv)
+	}
+	if err != nil {
+		report(err, "API token")
+		return fmt.Errorf("checks failed: %d", failures)
+	}
+	report(nil, "API token found in "+source)
+
+	apiClient := newAPIClient()
+	user, err := api.FetchBotUser(apiClient, bearerToken)
+	if err != nil {
+		report(err, "Notion accepts the token")
+		return fmt.Errorf("checks failed: %d", failures)
+	}
+	report(nil, describeUser(user))
+
+	results, err := apiClient.Search(api.SearchRequest{PageSize: 1}, bearerToken)
+	if err == nil && len(results.Results) == 0 {
+		err = errors.New("share pages with the integration from their ... menu in Notion")
+	}
+	report(err, "Pages are shared with the integration")
+
+	if failures > 0 {
+		return fmt.Errorf("checks failed: %d", failures)
+	}
+	return nil
+}
+
+// checkWritable checks that a file can be created in the directory, or, when it does not exist
+// yet, in the closest directory above it that does, without creating anything that stays behind.
+func checkWritable(dir string) error {
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	file, err := os.CreateTemp(dir, ".notionsync-doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/workspace"
+)
+
+// runList handles `notionsync ls [query]`, printing the ID, type and title of every page and database found.
+func runList(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	searchType := flags.String("type", "", "Only list objects of this type: page or database")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	objectType, err := workspace.ParseObjectType(*searchType)
+	if err != nil {
+		return err
+	}
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+
+	results, err := api.SearchAll(newAPIClient(), strings.Join(flags.Args(), " "), objectType, bearerToken)
+	if err != nil {
+		return fmt.Errorf("error searching workspace: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.ID, result.Object, titleOrUntitled(result.Title()))
+	}
+	return w.Flush()
+}
+
+// runTree handles `notionsync tree [query]`, printing the pages and databases found indented under their parents.
+func runTree(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	searchType := flags.String("type", "", "Only include objects of this type: page or database")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	objectType, err := workspace.ParseObjectType(*searchType)
+	if err != nil {
+		return err
+	}
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+
+	tree, err := workspace.Discover(newAPIClient(), strings.Join(flags.Args(), " "), objectType, bearerToken)
+	if err != nil {
+		return err
+	}
+	for _, root := range tree.Roots {
+		printNode(os.Stdout, root, 0)
+	}
+	return nil
+}
+
+func printNode(w io.Writer, node *workspace.Node, depth int) {
+	title := titleOrUntitled(node.Title)
+	if node.Object == "database" {
+		title += " [database]"
+	}
+	fmt.Fprintf(w, "%s%s  %s\n", strings.Repeat("  ", depth), title, node.ID)
+	for _, child := range node.Children {
+		printNode(w, child, depth+1)
+	}
+}
+
+func titleOrUntitled(title string) string {
+	if title == "" {
+		return "Untitled"
+	}
+	return title
+}
@@ -2,140 +2,277 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync"
+	"strings"
 
 	"github.com/s-kngstn/notionsync/api"
 	"github.com/s-kngstn/notionsync/format"
 	"github.com/s-kngstn/notionsync/pkg/cli"
-	"github.com/s-kngstn/notionsync/pkg/fetch"
-	"github.com/s-kngstn/notionsync/pkg/utils"
+	"github.com/s-kngstn/notionsync/pkg/config"
+	"github.com/s-kngstn/notionsync/pkg/fixture"
+	"github.com/s-kngstn/notionsync/pkg/logging"
+	"github.com/s-kngstn/notionsync/pkg/progress"
+	"github.com/s-kngstn/notionsync/pkg/token"
+	"golang.org/x/term"
 )
 
+// command is a subcommand of notionsync. run defines its flags on the flag set it is given, then parses args with them.
+type command struct {
+	name string
+	// usage is what follows the command name in the usage line.
+	usage   string
+	summary string
+	run     func(flags *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "pull", usage: "[flags] [url...]", summary: "Export Notion pages to markdown files", run: runPull},
+	{name: "push", usage: "[flags] <file> <url>", summary: "Add the content of a markdown file to a Notion page", run: runPush},
+	{name: "ls", usage: "[flags] [query]", summary: "List the pages and databases shared with the integration", run: runList},
+	{name: "tree", usage: "[flags] [query]", summary: "Print the hierarchy of the pages and databases shared with the integration", run: runTree},
+	{name: "cat", usage: "[flags] <url>", summary: "Render a single page as markdown on stdout", run: runCat},
+	{name: "status", usage: "[flags]", summary: "Show which pulled pages changed locally or in Notion since the last pull", run: runStatus},
+	{name: "sync", usage: "[flags] <profile>", summary: "Pull the pages of a profile from the config file", run: runProfile},
+	{name: "auth", usage: "[flags]", summary: "Check the API token and show the workspace it belongs to", run: runAuth},
+	{name: "doctor", usage: "[flags]", summary: "Check the token, API access, output directory and config file", run: runDoctor},
+}
+
+// errUsage is returned for a command line that could not be understood, once the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+// errPagesFailed is returned by a run that finished, but could not export every page.
+var errPagesFailed = errors.New("some pages failed to export")
+
 func main() {
-	var err error
-	bearerToken := os.Getenv("NOTION_API_KEY")
-	tokenFlag := flag.String("token", "", "Notion API bearer token")
-	filePath := flag.String("file", "", "Path to the file containing URLs to process")
-	outputDir := flag.String("dir", "notion-notes", "Directory to save markdown files in")
-	flag.Parse()
-
-	// Ensure output directory exists
-	if _, err := os.Stat(*outputDir); os.IsNotExist(err) {
-		os.Mkdir(*outputDir, 0755)
-	}
-
-	if bearerToken == "" && *tokenFlag == "" {
-		// Initialize RealUserInput with os.Stdin
-		fmt.Println("No Notion API Token found in env[`NOTION_API_KEY`] or flag provided")
-		if bearerToken == "" {
-			inputReader := bufio.NewReader(os.Stdin)
-			userInput := cli.NewRealUserInput(inputReader)
-			bearerToken = cli.Prompt(userInput, "Please enter the Notion API bearer token: ")
-		}
-	} else if *tokenFlag != "" {
-		// If token is provided through flag, use it
-		bearerToken = *tokenFlag
-	}
-
-	var urls []string
-
-	if *filePath == "" {
-		// No file path provided, prompt for a single URL
-		inputReader := bufio.NewReader(os.Stdin)
-		userInput := cli.NewRealUserInput(inputReader)
-		url := cli.Prompt(userInput, "Please enter the Notion page URL: ")
-		urls = append(urls, url)
-	} else {
-		// File path provided, read URLs from the file
-		urls, err = utils.ReadURLs(*filePath)
-		if err != nil {
-			fmt.Printf("Failed to read URLs from file: %v\n", err)
-			return
+	setupLogging(os.Stderr)
+	args := os.Args[1:]
+	// Flags without a command are the original command line, which pulls pages
+	name := "pull"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || len(os.Args) == 2 && (os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "--help") {
+		printUsage()
+		return
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(cmd.flagSet(), args)
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case errors.Is(err, errPagesFailed):
+		fmt.Fprintln(os.Stderr, "Error:", logging.Redact(err.Error()))
+		os.Exit(3)
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", logging.Redact(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
 	}
+	return command{}, false
+}
 
-	client := &http.Client{}
-	apiClient := api.NewNotionApiClient(client)
+func printUsage() {
+	fmt.Println("Usage: notionsync <command> [flags] [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println()
+	fmt.Println("Run notionsync <command> -help to see the flags of a command.")
+	fmt.Println("Flags given without a command are passed to pull.")
+}
 
-	var wg sync.WaitGroup // WaitGroup to wait for all goroutines to finish
-	// This mutal exclusion is for safely updating the processedBlocks map
-	var mu sync.Mutex
+// logFlags are the flags choosing what every command logs.
+var logFlags struct {
+	verbose, quiet, json bool
+}
 
-	processedBlocks := make(map[string]map[string]string)
+// setupLogging sends the log to w, at the level chosen with -v or -q, with tokens redacted.
+func setupLogging(w io.Writer) {
+	level := slog.LevelInfo
+	switch {
+	case logFlags.quiet:
+		level = slog.LevelError
+	case logFlags.verbose:
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(logging.New(w, level, logFlags.json))
+}
 
-	for _, url := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			processURL(url, apiClient, bearerToken, &mu, processedBlocks, *outputDir)
-		}(url)
+// flagSet creates the flag set for the command, with a usage message that lists its flags.
+func (c command) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	flags.BoolVar(&logFlags.verbose, "v", false, "Log more, including every request sent to Notion")
+	flags.BoolVar(&logFlags.quiet, "q", false, "Only log errors")
+	flags.BoolVar(&logFlags.json, "log-json", false, "Write the log as JSON lines")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n\n%s.\n", strings.TrimSpace("notionsync "+c.name+" "+c.usage), c.summary)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(flags.Output(), "\nFlags:")
+			flags.PrintDefaults()
+		}
 	}
+	return flags
+}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
+// parseFlags parses a command's arguments, returning errUsage when they are invalid, and sets up logging as they ask.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		// The flag package has already explained the problem
+		return errUsage
+	}
+	setupLogging(os.Stderr)
+	return err
+}
 
-	// @TODO
-	// Lets check the contents of the outputDir to see if any files have been created. If the directory is empty, we can skip this message
-	// if the contents is less than 2, and more than 0 we can say URL processed
-	dir, err := os.ReadDir(*outputDir)
-	if err != nil {
-		fmt.Printf("Error reading directory: %v\n", err)
-		return
+// usageError prints a problem with a command's arguments along with its usage.
+func usageError(flags *flag.FlagSet, message string) error {
+	fmt.Fprintln(flags.Output(), message)
+	flags.Usage()
+	return errUsage
+}
+
+// tokenFlags are the flags choosing the token of every command that talks to Notion.
+type tokenFlags struct {
+	token, login *string
+}
+
+func addTokenFlags(flags *flag.FlagSet) tokenFlags {
+	return tokenFlags{
+		token: flags.String("token", "", "Notion API bearer token, read from "+config.DefaultTokenEnv+" or the default saved login when not given"),
+		login: flags.String("login", "", "Name of a workspace saved with auth login whose token to use"),
 	}
+}
 
+// find looks for a token without asking for one, returning where it was found, or an empty token when there is none.
+// The order is -token, -login, NOTION_API_KEY and then the default saved login.
+func (t tokenFlags) find() (bearerToken, source string, err error) {
+	defer func() { logging.AddSecret(bearerToken) }()
 	switch {
-	case len(dir) == 0:
-		fmt.Println("No URLs processed")
-	case len(dir) == 1:
-		fmt.Println("URL processed")
-	default:
-		fmt.Println("URLs processed")
+	case *t.token != "":
+		return *t.token, "-token", nil
+	case *t.login != "":
+		credential, err := savedCredential(*t.login)
+		return credential.Token, fmt.Sprintf("saved login %q", *t.login), err
+	}
+	if bearerToken := os.Getenv(config.DefaultTokenEnv); bearerToken != "" {
+		return bearerToken, config.DefaultTokenEnv, nil
 	}
+	credential, err := savedCredential("")
+	if errors.Is(err, token.ErrNotFound) {
+		return "", "", nil
+	}
+	return credential.Token, fmt.Sprintf("saved login %q", credential.Name), err
 }
 
-func processURL(url string, apiClient api.NotionAPI, bearerToken string, mu *sync.Mutex, processedBlocks map[string]map[string]string, outputDir string) {
-	// Checking if the URL is a notion page
-	urlChecker := fetch.DefaultURLChecker{}
-	urlIsValid, err := urlChecker.CheckURL(url)
-	if err != nil {
-		fmt.Printf("%v\n", err)
+// resolve returns the token found by find. Without one it asks for it, unless stdin is not a
+// terminal, in which case it fails instead.
+func (t tokenFlags) resolve() (string, error) {
+	bearerToken, _, err := t.find()
+	if err != nil || bearerToken != "" {
+		return bearerToken, err
 	}
+	bearerToken, err = promptFor(
+		"No Notion API Token found in env[`NOTION_API_KEY`] or flag provided",
+		"Please enter the Notion API bearer token: ",
+		fmt.Errorf("no Notion API token found, set %s, pass -token or save one with auth login", config.DefaultTokenEnv),
+	)
+	logging.AddSecret(bearerToken)
+	return bearerToken, err
+}
 
-	if !urlIsValid {
-		return
+// formatFlags are the flags choosing the markdown that is written.
+type formatFlags struct {
+	format, dialect *string
+}
+
+func addFormatFlags(flags *flag.FlagSet) formatFlags {
+	return formatFlags{
+		format:  flags.String("format", "markdown", "Markdown flavour to write: markdown, obsidian, hugo, jekyll or docusaurus"),
+		dialect: flags.String("dialect", "gfm", "Markdown dialect to write: gfm, commonmark or strict"),
 	}
-	blockIDFetcher := fetch.DefaultBlockIDFetcher{}
-	uuid, err := blockIDFetcher.GetBlockID(url)
+}
+
+// options checks the flags and returns the options they describe.
+func (f formatFlags) options() (format.Options, error) {
+	flavour, err := format.ParseFlavour(*f.format)
 	if err != nil {
-		fmt.Printf("Error extracting UUID from URL %s: %v\n", url, err)
-		return
+		return format.Options{}, err
 	}
-	pageName, err := fetch.ExtractNameFromURL(url)
+	dialect, err := format.ParseDialect(*f.dialect)
 	if err != nil {
-		fmt.Printf("Error extracting page name from URL %s: %v\n", url, err)
-		return
+		return format.Options{}, err
 	}
+	return format.Options{Flavour: flavour, Dialect: dialect}, nil
+}
 
-	results, err := api.FetchChildBlocks(apiClient, uuid, bearerToken)
-	if err != nil {
-		fmt.Printf("Error calling API for URL %s: %v\n", url, err)
-		// @todo if there is a error here we need to do a graceful shutdown and have the user try again
-		return
+// promptFor asks the user for a missing value. When stdin is not a terminal there is nobody to
+// ask, so it returns the missing error instead of waiting on input that will not come.
+func promptFor(notice, question string, missing error) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", missing
 	}
-
-	outputPath := fmt.Sprintf("%s/%s.md", outputDir, pageName)
-	// Initialize the inner map if it doesn't exist
-	mu.Lock()
-	if processedBlocks[uuid] == nil {
-		processedBlocks[uuid] = make(map[string]string)
+	fmt.Println(notice)
+	userInput := cli.NewRealUserInput(bufio.NewReader(os.Stdin))
+	value := cli.Prompt(userInput, question)
+	if value == "" {
+		return "", missing
 	}
-	processedBlocks[uuid]["outputPath"] = outputPath
-	// You can add more entries to processedBlocks[uuid] as needed
-	mu.Unlock()
+	return value, nil
+}
 
-	format.ProcessBlocks(uuid, results, outputPath, pageName, apiClient, bearerToken, processedBlocks[uuid], outputDir)
+// isTerminal reports whether f is an interactive terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// apiURLEnv points every command at another server than Notion's, such as a fake one in tests.
+const apiURLEnv = "NOTIONSYNC_API_URL"
+
+// newAPIClient creates the client used to talk to Notion, which keeps to Notion's rate limit,
+// and logs every request it sends, retries included, with -v.
+func newAPIClient() api.NotionAPI {
+	return newTrackedAPIClient(nil, &http.Client{})
+}
+
+// newTrackedAPIClient creates a client that sends its requests with transport, and sends an event to
+// events for every request and rate limit wait.
+func newTrackedAPIClient(events progress.Listener, transport api.HttpClientInterface) api.NotionAPI {
+	client := api.NewRateLimitedClient(api.NewLoggingClient(transport), api.DefaultRequestsPerSecond)
+	if _, ok := transport.(*fixture.Replayer); ok {
+		// Recorded responses are not subject to Notion's rate limit
+		client.Interval = 0
+	}
+	client.Events = events
+	apiClient := api.NewNotionApiClient(client)
+	if baseURL := os.Getenv(apiURLEnv); baseURL != "" {
+		apiClient.BaseURL = baseURL
+	}
+	return apiClient
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/s-kngstn/notionsync/pkg/config"
+	"github.com/s-kngstn/notionsync/pkg/token"
+)
+
+// runProfile handles `notionsync sync [-config file] <profile>`, running a sync job defined in a config file.
+func runProfile(flags *flag.FlagSet, args []string) error {
+	configPath := flags.String("config", "", "Path to the config file, by default "+config.FileName+" in the working directory or the user config directory")
+	runFlags := addRunFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	path, err := config.Find(*configPath)
+	if err != nil {
+		return err
+	}
+	file, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, fmt.Sprintf("Expected the name of a profile to sync, one of: %v", file.Names()))
+	}
+	name := flags.Arg(0)
+	profile, err := file.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	job, err := profileJob(profile)
+	if err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	if err := runFlags.apply(&job); err != nil {
+		return usageError(flags, err.Error())
+	}
+	if job.bearerToken == "" && !job.offline && job.replayFile == "" {
+		if job.bearerToken, err = defaultToken(name); err != nil {
+			return err
+		}
+	}
+
+	return runSync(job)
+}
+
+// profileJob builds a sync job from a profile, using the command line defaults for anything the profile leaves out.
+func profileJob(profile config.Profile) (syncJob, error) {
+	outputDir := profile.OutputDir()
+	if outputDir == "" {
+		outputDir = "notion-notes"
+	}
+	job, err := newSyncJob(outputDir, profile.Format, profile.Dialect, profile.Layout, profile.FollowLinks, profile.SearchType)
+	if err != nil {
+		return syncJob{}, err
+	}
+	if profile.Concurrency > 0 {
+		job.concurrency = profile.Concurrency
+	}
+	if profile.MaxDepth != nil {
+		job.scope.MaxDepth = *profile.MaxDepth
+	}
+	job.scope.MaxPages = profile.MaxPages
+	job.scope.Include = profile.Include
+	job.scope.Exclude = profile.Exclude
+	job.workspace = profile.Workspace
+	job.searchQuery = profile.Search
+
+	if profile.Login != "" {
+		credential, err := savedCredential(profile.Login)
+		if err != nil {
+			return syncJob{}, err
+		}
+		job.bearerToken = credential.Token
+	} else if job.bearerToken, err = profile.ResolveToken(); err != nil {
+		return syncJob{}, err
+	}
+	if job.targets, err = profile.ReadTargets(); err != nil {
+		return syncJob{}, fmt.Errorf("invalid targets:\n%w", err)
+	}
+	if len(job.targets) == 0 && !job.workspace {
+		return syncJob{}, fmt.Errorf("no targets to sync, add targets, a file or workspace: true")
+	}
+	return job, nil
+}
+
+// defaultToken is the token for a profile that does not set one: the default saved login, or else one typed in at the terminal.
+func defaultToken(name string) (string, error) {
+	credential, err := savedCredential("")
+	if err == nil {
+		return credential.Token, nil
+	}
+	if !errors.Is(err, token.ErrNotFound) {
+		return "", err
+	}
+	return promptFor(
+		fmt.Sprintf("No Notion API Token found for profile %s", name),
+		"Please enter the Notion API bearer token: ",
+		fmt.Errorf("no Notion API token found for profile %s", name),
+	)
+}
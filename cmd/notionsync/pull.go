@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+	"github.com/s-kngstn/notionsync/pkg/cli"
+	"github.com/s-kngstn/notionsync/pkg/crawl"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+	"github.com/s-kngstn/notionsync/pkg/fixture"
+	"github.com/s-kngstn/notionsync/pkg/logging"
+	"github.com/s-kngstn/notionsync/pkg/manifest"
+	"github.com/s-kngstn/notionsync/pkg/plan"
+	"github.com/s-kngstn/notionsync/pkg/progress"
+	"github.com/s-kngstn/notionsync/pkg/utils"
+	"github.com/s-kngstn/notionsync/pkg/workspace"
+	"golang.org/x/term"
+)
+
+// syncJob is everything a sync run needs, whether it comes from the command line flags or from a profile.
+type syncJob struct {
+	bearerToken string
+	outputDir   string
+	opts        format.Options
+	concurrency int
+	scope       crawl.Scope
+	targets     []utils.Target
+	// workspace exports everything found by searching for searchQuery, limited to objects of objectType.
+	workspace   bool
+	searchQuery string
+	objectType  string
+	// prune deletes the files of pages from earlier runs that this run did not write.
+	prune bool
+	// staged exports everything into a staging directory first, and only replaces the files in
+	// outputDir when every page was exported.
+	staged bool
+	// reportFile is where to write the result of every page as JSON, when it is set.
+	reportFile string
+	// dryRun prints what the run would do instead of doing it, as JSON to planJSON when it is set.
+	dryRun   bool
+	planJSON string
+	// resume carries on from the state the last run left in outputDir, when there is one.
+	resume bool
+	// failedFile is where to write the pages that failed as a URL list, when it is set.
+	failedFile string
+	// cacheDir keeps the responses from Notion between runs when it is set, and offline renders from it alone.
+	cacheDir string
+	offline  bool
+	// recordFile is where to record every exchange with Notion as a fixture, and replayFile is a
+	// fixture to answer requests from instead of Notion, when they are set.
+	recordFile string
+	replayFile string
+}
+
+// runFlags are the flags of pull and sync that change what a run does to the output directory.
+type runFlags struct {
+	prune    *bool
+	staged   *bool
+	report   *string
+	dryRun   *bool
+	planJSON *string
+	resume   *bool
+	failed   *string
+	cache    *string
+	offline  *bool
+	record   *string
+	replay   *string
+}
+
+func addRunFlags(flags *flag.FlagSet) runFlags {
+	return runFlags{
+		prune:    flags.Bool("prune", false, "Delete the files of pages pulled before that this run does not write, such as deleted or moved pages"),
+		staged:   flags.Bool("staged", false, "Export into a staging directory, and only move the files into the output directory if every page was exported"),
+		report:   flags.String("report", "", "Write the result of every page, exported, skipped or failed, as JSON to this file"),
+		dryRun:   flags.Bool("dry-run", false, "Print the files that would be created, updated, left alone and pruned, with a diff of each update, without writing anything"),
+		planJSON: flags.String("plan-json", "", "Write the dry run plan as JSON to this file, - for stdout. Implies -dry-run"),
+		resume:   flags.Bool("resume", false, "Carry on from where an interrupted or failed run into the same directory stopped, without exporting the pages it finished again"),
+		failed:   flags.String("failed", "", "Write the pages that failed to this file as a URL list, to retry them with -file"),
+		cache:    flags.String("cache", "", "Keep what is fetched from Notion in this directory, and only fetch a page's content again once it has been edited"),
+		offline:  flags.Bool("offline", false, "Render from the -cache directory alone, without sending any requests to Notion"),
+		record:   flags.String("record", "", "Record every request to Notion and its response in this file, with tokens removed, to reproduce a problem with -replay"),
+		replay:   flags.String("replay", "", "Answer requests from a file written by -record instead of sending them to Notion"),
+	}
+}
+
+// apply sets the flags on the job, and returns an error for flags that cannot be used together.
+func (f runFlags) apply(job *syncJob) error {
+	job.prune = *f.prune
+	job.staged = *f.staged
+	job.reportFile = *f.report
+	job.dryRun = *f.dryRun || *f.planJSON != ""
+	job.planJSON = *f.planJSON
+	job.resume = *f.resume
+	job.failedFile = *f.failed
+	job.cacheDir = *f.cache
+	job.offline = *f.offline
+	job.recordFile = *f.record
+	job.replayFile = *f.replay
+	if job.offline && job.cacheDir == "" {
+		return errors.New("-offline needs a -cache directory to render from")
+	}
+	if job.resume && (job.staged || job.dryRun) {
+		// Neither kind of run writes into the output directory as it goes, so there is nothing to carry on from
+		return errors.New("-resume cannot be used with -staged or -dry-run")
+	}
+	return nil
+}
+
+// runPull handles `notionsync pull`, exporting the pages given as arguments, in a URL list file or found in the workspace.
+func runPull(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	filePath := flags.String("file", "", "Path to the file containing URLs to process")
+	outputDir := flags.String("dir", "notion-notes", "Directory to save markdown files in")
+	formatFlags := addFormatFlags(flags)
+	layoutFlag := flags.String("layout", "flat", "Where to write child pages for markdown and obsidian: flat or nested")
+	concurrency := flags.Int("concurrency", crawl.DefaultConcurrency, "Number of pages to export at the same time")
+	maxDepth := flags.Int("max-depth", -1, "Number of child page and link levels to crawl below each URL, -1 for no limit")
+	followLinks := flags.String("follow-links", "all", "Linked pages to crawl: none, same-tree or all")
+	maxPages := flags.Int("max-pages", 0, "Most pages to export in a run, 0 for no limit")
+	var include, exclude cli.StringList
+	flags.Var(&include, "include", "Only crawl child and linked pages whose title or ID matches this pattern (repeatable)")
+	flags.Var(&exclude, "exclude", "Do not crawl pages whose title or ID matches this pattern (repeatable)")
+	exportWorkspace := flags.Bool("workspace", false, "Export every page and database shared with the integration instead of a list of URLs")
+	searchQuery := flags.String("search", "", "With -workspace, only export pages and databases whose title matches this query")
+	searchType := flags.String("search-type", "", "With -workspace, only search for objects of this type: page or database")
+	runFlags := addRunFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	job, err := newSyncJob(*outputDir, *formatFlags.format, *formatFlags.dialect, *layoutFlag, *followLinks, *searchType)
+	if err != nil {
+		return err
+	}
+	job.concurrency = *concurrency
+	job.scope.MaxDepth = *maxDepth
+	job.scope.MaxPages = *maxPages
+	job.scope.Include = include
+	job.scope.Exclude = exclude
+	job.workspace = *exportWorkspace
+	job.searchQuery = *searchQuery
+	if err := runFlags.apply(&job); err != nil {
+		return usageError(flags, err.Error())
+	}
+
+	if *filePath != "" {
+		// File path provided, read URLs and their options from the file
+		job.targets, err = utils.ReadTargets(*filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read URLs from file:\n%w", err)
+		}
+	}
+	for _, arg := range flags.Args() {
+		target, err := utils.NewTarget(arg)
+		if err != nil {
+			return err
+		}
+		job.targets = append(job.targets, target)
+	}
+
+	// Offline and replayed runs never talk to Notion, so they do not need a token
+	if !job.offline && job.replayFile == "" {
+		if job.bearerToken, err = tokens.resolve(); err != nil {
+			return err
+		}
+	}
+
+	if len(job.targets) == 0 && !job.workspace && !job.resume {
+		// No pages given, ask for a single URL
+		url, err := promptFor("No page URLs given", "Please enter the Notion page URL: ",
+			errors.New("no pages to pull, pass page URLs, -file or -workspace"))
+		if err != nil {
+			return err
+		}
+		ref, err := fetch.Resolve(url)
+		if err != nil {
+			return err
+		}
+		job.targets = append(job.targets, utils.Target{Ref: ref})
+	}
+
+	return runSync(job)
+}
+
+// newSyncJob checks the settings given by name, and creates a job with the default concurrency and scope.
+func newSyncJob(outputDir, formatName, dialectName, layoutName, followLinks, searchType string) (syncJob, error) {
+	flavour, err := format.ParseFlavour(formatName)
+	if err != nil {
+		return syncJob{}, err
+	}
+	dialect, err := format.ParseDialect(dialectName)
+	if err != nil {
+		return syncJob{}, err
+	}
+	layout, err := format.ParseLayout(layoutName)
+	if err != nil {
+		return syncJob{}, err
+	}
+	linkPolicy, err := crawl.ParseLinkPolicy(followLinks)
+	if err != nil {
+		return syncJob{}, err
+	}
+	objectType, err := workspace.ParseObjectType(searchType)
+	if err != nil {
+		return syncJob{}, err
+	}
+
+	scope := crawl.DefaultScope()
+	scope.FollowLinks = linkPolicy
+	return syncJob{
+		outputDir:   outputDir,
+		opts:        format.Options{Flavour: flavour, Dialect: dialect, Layout: layout},
+		concurrency: crawl.DefaultConcurrency,
+		scope:       scope,
+		objectType:  objectType,
+	}, nil
+}
+
+// runSync exports the job's pages into its output directory, and records what was written in the manifest there.
+// A dry run renders the pages in memory and prints the plan instead.
+func runSync(job syncJob) error {
+	logging.AddSecret(job.bearerToken)
+	var recorder *plan.Recorder
+	if job.dryRun {
+		recorder = plan.NewRecorder()
+		job.opts.Sink = recorder
+	} else if err := os.MkdirAll(job.outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	// A staged run exports into a directory next to the output directory, on the same file system
+	// so that its files can be moved across once every page has been written
+	exportDir := job.outputDir
+	if job.staged && !job.dryRun {
+		outputDir := filepath.Clean(job.outputDir)
+		stagingDir, err := os.MkdirTemp(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+"-staging-*")
+		if err != nil {
+			return fmt.Errorf("error creating staging directory: %w", err)
+		}
+		defer os.RemoveAll(stagingDir)
+		exportDir = stagingDir
+	}
+
+	// A staged run counts its files as they are moved into the output directory
+	stats := &format.WriteStats{}
+	if exportDir == job.outputDir {
+		job.opts.Stats = stats
+	}
+	// A resumed run does not export the pages the last run finished again
+	var state *crawl.State
+	if job.resume {
+		var err error
+		if state, err = crawl.LoadState(job.outputDir); err != nil {
+			return err
+		}
+		if state == nil {
+			if len(job.targets) == 0 && !job.workspace {
+				return fmt.Errorf("there is no interrupted run in %s to resume", job.outputDir)
+			}
+			slog.Info("no interrupted run to resume, starting from the beginning", "dir", job.outputDir)
+		}
+	}
+
+	display, stopProgress := startProgress()
+	defer stopProgress()
+	job.opts.Events = display.Handle
+	job.opts.Offline = job.offline
+	opts := flavourOptions(job.opts, exportDir)
+	var transport api.HttpClientInterface = &http.Client{}
+	if job.replayFile != "" {
+		replayer, err := fixture.LoadReplayer(job.replayFile)
+		if err != nil {
+			return err
+		}
+		transport = replayer
+	}
+	if job.recordFile != "" {
+		recorder := fixture.NewRecorder(transport)
+		transport = recorder
+		// A run that fails is recorded too, as it is often the one worth reproducing
+		defer func() {
+			if err := recorder.Save(job.recordFile); err != nil {
+				slog.Error("error saving recording", "err", err)
+			}
+		}()
+	}
+	apiClient := newTrackedAPIClient(display.Handle, transport)
+	if job.cacheDir != "" {
+		apiClient = api.NewCachingClient(apiClient, job.cacheDir, job.offline)
+	}
+
+	// Static site generators read pages from their own content directory
+	contentDir := filepath.Join(exportDir, opts.Flavour.ContentDir())
+
+	report := crawl.NewReport()
+	var roots []crawl.Task
+	if job.workspace {
+		tree, err := workspace.Discover(apiClient, job.searchQuery, job.objectType, job.bearerToken)
+		if err != nil {
+			return err
+		}
+		slog.Info("found pages and databases shared with the integration", "count", tree.Len())
+		roots = tree.Tasks(contentDir)
+	}
+	for _, target := range job.targets {
+		if root, ok := processTarget(target, exportDir, opts, job.scope.MaxDepth, report); ok {
+			roots = append(roots, root)
+		}
+	}
+
+	crawler := crawl.NewCrawler(apiClient, job.bearerToken, opts, contentDir)
+	crawler.Concurrency = job.concurrency
+	crawler.Scope = job.scope
+	crawler.Report = report
+	if !job.dryRun && !job.staged {
+		// The state is saved as the crawl goes, so that an interrupted run can be resumed
+		crawler.StateDir = job.outputDir
+	}
+	if state != nil {
+		crawler.Restore(state, job.outputDir)
+	}
+	crawler.Run(roots)
+	stopProgress()
+
+	if job.dryRun {
+		return printPlan(job, recorder, crawler.Registry)
+	}
+	failed := report.Count(crawl.Failed)
+	if exportDir != job.outputDir {
+		if failed > 0 {
+			if err := writeReport(job, report, crawler, exportDir); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d pages failed to export, so nothing in %s was changed: %w", failed, job.outputDir, errPagesFailed)
+		}
+		if err := commitStaged(exportDir, job.outputDir, crawler.Registry, report, stats); err != nil {
+			return err
+		}
+	}
+	// Anything edited in Notion since the crawl started shows up as changed in the next status
+	if err := recordPages(job.outputDir, crawler.Registry, crawler.StartedAt(), job.prune); err != nil {
+		return err
+	}
+
+	if err := writeReport(job, report, crawler, exportDir); err != nil {
+		return err
+	}
+	fmt.Printf("%d files created, %d updated, %d unchanged\n",
+		stats.Count(atomicfile.Created), stats.Count(atomicfile.Updated), stats.Count(atomicfile.Unchanged))
+	if failed > 0 {
+		slog.Info("run again with -resume to retry the pages that failed")
+		return fmt.Errorf("%d pages failed to export: %w", failed, errPagesFailed)
+	}
+	// Every page was exported, so there is nothing left to resume
+	return crawl.RemoveState(job.outputDir)
+}
+
+// startProgress shows the progress of a run on stderr until the returned function is called: on a line
+// redrawn in place when stderr is a terminal, and as a log line every few seconds when it is not.
+// Nothing is shown with -q.
+func startProgress() (*progress.Display, func()) {
+	live := term.IsTerminal(int(os.Stderr.Fd()))
+	display := progress.NewDisplay(os.Stderr, live)
+	if logFlags.quiet {
+		return display, func() {}
+	}
+	if live {
+		// Log lines replace the progress line rather than run into it
+		setupLogging(display.LogWriter(os.Stderr))
+	}
+	display.Start()
+	return display, func() {
+		display.Stop()
+		setupLogging(os.Stderr)
+	}
+}
+
+// writeReport prints the pages that failed or were skipped, writes the result of every page to the
+// job's report file, and writes the pages that failed to the job's failed file.
+func writeReport(job syncJob, report *crawl.Report, crawler *crawl.Crawler, exportDir string) error {
+	if err := report.Print(os.Stdout); err != nil {
+		return err
+	}
+	if job.failedFile != "" {
+		if err := writeFailedTargets(job, report, crawler.Failed(), exportDir); err != nil {
+			return err
+		}
+	}
+	if job.reportFile == "" {
+		return nil
+	}
+	file, err := os.Create(job.reportFile)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer file.Close()
+	return report.WriteJSON(file)
+}
+
+// writeFailedTargets writes the pages that failed to the job's failed file as a URL list, with the
+// options that put each page back where it would have been written, so that the file can be passed
+// to -file to try them again.
+func writeFailedTargets(job syncJob, report *crawl.Report, failed []crawl.Task, exportDir string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Pages that failed to export into %s, retry them with -file %s\n", job.outputDir, job.failedFile)
+	for _, task := range failed {
+		line := failedTarget(job, task, exportDir).String()
+		if result, ok := report.Result(task.PageID); ok && result.ErrorKind != "" {
+			line += "  # " + string(result.ErrorKind)
+		}
+		buf.WriteString(line + "\n")
+	}
+	if err := os.WriteFile(job.failedFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing failed pages: %w", err)
+	}
+	return nil
+}
+
+// failedTarget turns a page that failed into a URL list entry that exports it into the same directory,
+// in the same format and down to the same depth.
+func failedTarget(job syncJob, task crawl.Task, exportDir string) utils.Target {
+	flavour, maxDepth := job.opts.Flavour, job.scope.MaxDepth
+	if task.Settings != nil {
+		flavour, maxDepth = task.Settings.Options.Flavour, task.Settings.MaxDepth
+	}
+	target := utils.Target{Ref: fetch.Reference{Type: fetch.PageRef, ID: task.PageID}, Name: task.Name}
+	if flavour != job.opts.Flavour {
+		target.Flavour = flavour
+	}
+	dir, err := filepath.Rel(filepath.Join(exportDir, flavour.ContentDir()), task.Dir)
+	if err == nil && dir != "." && !strings.HasPrefix(dir, "..") {
+		target.Dir = dir
+	}
+	if maxDepth >= 0 {
+		depth := max(maxDepth-task.Depth, 0)
+		target.Depth = &depth
+	}
+	return target
+}
+
+// commitStaged moves the files of a staged export into the output directory, replacing the files
+// there one by one and leaving alone the ones that did not change, and points the registry and the
+// report at where the pages ended up.
+func commitStaged(stagingDir, outputDir string, registry *crawl.Registry, report *crawl.Report, stats *format.WriteStats) error {
+	err := filepath.WalkDir(stagingDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(stagingDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outputDir, rel)
+		staged, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		existing, err := os.ReadFile(target)
+		switch {
+		case err == nil && bytes.Equal(existing, staged):
+			stats.Add(atomicfile.Unchanged)
+			return nil
+		case err == nil:
+			stats.Add(atomicfile.Updated)
+		case errors.Is(err, fs.ErrNotExist):
+			stats.Add(atomicfile.Created)
+		default:
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return fmt.Errorf("error moving staged export into %s: %w", outputDir, err)
+	}
+
+	for pageID, path := range registry.Written() {
+		if rel, err := filepath.Rel(stagingDir, path); err == nil {
+			registry.SetPath(pageID, filepath.Join(outputDir, rel))
+			report.SetPath(pageID, filepath.Join(outputDir, rel))
+		}
+	}
+	return nil
+}
+
+// printPlan prints what a dry run would have done to the output directory.
+func printPlan(job syncJob, recorder *plan.Recorder, registry *crawl.Registry) error {
+	var pruned map[string]string
+	if job.prune {
+		m, err := manifest.Load(job.outputDir)
+		if err != nil {
+			return err
+		}
+		pruned = stalePages(job.outputDir, m, registry)
+	}
+	p, err := plan.Build(job.outputDir, recorder.Files(), registry.Written(), pruned)
+	if err != nil {
+		return err
+	}
+
+	switch job.planJSON {
+	case "":
+		return p.Print(os.Stdout)
+	case "-":
+		return p.WriteJSON(os.Stdout)
+	}
+	file, err := os.Create(job.planJSON)
+	if err != nil {
+		return fmt.Errorf("error creating plan file: %w", err)
+	}
+	defer file.Close()
+	if err := p.WriteJSON(file); err != nil {
+		return err
+	}
+	return p.Print(os.Stdout)
+}
+
+// recordPages adds the pages written in a run to the manifest in the output directory,
+// after deleting the files of pages the run did not write when prune is set.
+func recordPages(outputDir string, registry *crawl.Registry, syncedAt time.Time, prune bool) error {
+	m, err := manifest.Load(outputDir)
+	if err != nil {
+		return err
+	}
+	if prune {
+		for pageID, path := range stalePages(outputDir, m, registry) {
+			if err := os.Remove(path); err != nil {
+				slog.Error("error pruning page", "path", path, "err", err)
+				continue
+			}
+			// A page that moved is recorded again at its new path below
+			if !registry.Claimed(pageID) {
+				delete(m.Pages, pageID)
+			}
+			slog.Info("pruned page", "path", path)
+		}
+	}
+	for pageID, path := range registry.Written() {
+		if err := m.Record(outputDir, pageID, path, syncedAt); err != nil {
+			slog.Error("error recording page in manifest", "page_id", pageID, "err", err)
+		}
+	}
+	return m.Save(outputDir)
+}
+
+// stalePages returns the files in the manifest that a run did not write: pages it did not reach,
+// and the old files of pages it wrote somewhere else. Pages that were reached but could not be
+// written keep their files, so that a failed request does not delete anything.
+func stalePages(outputDir string, m *manifest.Manifest, registry *crawl.Registry) map[string]string {
+	stale := make(map[string]string)
+	for pageID, entry := range m.Pages {
+		path := filepath.Join(outputDir, filepath.FromSlash(entry.Path))
+		written, ok := registry.Path(pageID)
+		if ok && filepath.Clean(written) == path || !ok && registry.Claimed(pageID) {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			stale[pageID] = path
+		}
+	}
+	return stale
+}
+
+// processTarget turns an entry of the URL list into the task that starts crawling from that page.
+func processTarget(target utils.Target, outputDir string, opts format.Options, maxDepth int, report *crawl.Report) (crawl.Task, bool) {
+	ref := target.Ref
+	if ref.Type == fetch.DatabaseRef {
+		slog.Warn("skipping database, databases can only be exported with -workspace", "database", ref.String())
+		report.Add(crawl.PageResult{PageID: ref.ID, Name: firstNonEmpty(target.Name, ref.Name), Status: crawl.Skipped, Reason: "databases can only be exported with -workspace"})
+		return crawl.Task{}, false
+	}
+
+	// A block reference exports the block and the blocks nested in it
+	task := crawl.Task{PageID: ref.ID, Name: ref.Name}
+	if target.Name != "" {
+		task.Name = target.Name
+	} else if task.Name == "" {
+		task.Name = api.NormalizeID(ref.ID)
+	}
+
+	// Static site generators read pages from their own content directory
+	task.Dir = filepath.Join(outputDir, opts.Flavour.ContentDir())
+	if target.Dir == "" && target.Depth == nil && target.Flavour == "" {
+		return task, true
+	}
+
+	if target.Flavour != "" {
+		opts.Flavour = target.Flavour
+		opts = flavourOptions(opts, outputDir)
+	}
+	if target.Depth != nil {
+		maxDepth = *target.Depth
+	}
+	task.Dir = filepath.Join(outputDir, opts.Flavour.ContentDir(), target.Dir)
+	task.Settings = &crawl.Settings{Options: opts, LinkedDir: task.Dir, MaxDepth: maxDepth}
+	return task, true
+}
+
+// flavourOptions sets up the options that depend on the flavour being written.
+func flavourOptions(opts format.Options, outputDir string) format.Options {
+	opts.AssetDir = ""
+	// Obsidian embeds downloaded copies of images and files
+	if opts.Flavour == format.Obsidian {
+		opts.AssetDir = filepath.Join(outputDir, "assets")
+		if opts.Sink == nil {
+			os.MkdirAll(opts.AssetDir, 0755)
+		}
+	}
+	return opts
+}
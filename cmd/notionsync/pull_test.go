@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/fakenotion"
+)
+
+// TestPull runs the pull command against a fake Notion server, the whole way from the command line
+// to the files written. Requests keep to Notion's rate limit, so the workspace is kept small.
+func TestPull(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    bool
+		expected error
+		files    []string
+	}{
+		{"every page", false, nil, []string{"guide.md", "setup.md"}},
+		{"failed page", true, errPagesFailed, []string{"guide.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakenotion.New("test-token")
+			defer server.Close()
+			guide := server.AddPage("", "Guide", api.Block{Type: "paragraph", Paragraph: &api.Paragraph{
+				RichText: []api.RichText{{Type: "text", Text: api.Text{Content: "Read me first"}, PlainText: "Read me first"}},
+			}})
+			setup := server.AddPage(guide, "Setup")
+			if tt.fault {
+				server.Inject(fakenotion.Fault{Status: http.StatusInternalServerError, Path: "/v1/blocks/" + setup})
+			}
+			t.Setenv(apiURLEnv, server.BaseURL())
+
+			dir := t.TempDir()
+			cmd, _ := findCommand("pull")
+			err := cmd.run(cmd.flagSet(), []string{"-q", "-token", "test-token", "-dir", dir, "https://www.notion.so/Guide-" + api.NormalizeID(guide)})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Expected error %v, got %v", tt.expected, err)
+			}
+
+			for _, name := range tt.files {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("Expected %s to be written: %v", name, err)
+				}
+			}
+			content, _ := os.ReadFile(filepath.Join(dir, "guide.md"))
+			if !strings.Contains(string(content), "Read me first") {
+				t.Errorf("Expected the page's content in guide.md, got:\n%s", content)
+			}
+		})
+	}
+}
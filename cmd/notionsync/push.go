@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+)
+
+// runPush handles `notionsync push <file> <url>`, adding the blocks of a markdown file to the end of a page.
+func runPush(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	replace := flags.Bool("replace", false, "Delete the content of the page before adding the file's, keeping its child pages and databases")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return usageError(flags, "Expected a markdown file and the page to push it to")
+	}
+
+	markdown, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	ref, err := fetch.Resolve(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if ref.Type == fetch.DatabaseRef {
+		return fmt.Errorf("cannot push to %s, only to a page", ref)
+	}
+	blocks := format.ParseMarkdown(string(markdown))
+
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+	apiClient := newAPIClient()
+
+	if *replace {
+		results, err := api.FetchChildBlocks(apiClient, ref.ID, bearerToken)
+		if err != nil {
+			return err
+		}
+		for _, block := range results.Results {
+			// Deleting a child page or database would delete everything in it too
+			if block.Type == "child_page" || block.Type == "child_database" {
+				continue
+			}
+			if err := apiClient.DeleteBlock(block.ID, bearerToken); err != nil {
+				return fmt.Errorf("error deleting block %s: %w", block.ID, err)
+			}
+		}
+	}
+
+	if err := apiClient.AppendBlockChildren(ref.ID, blocks, bearerToken); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed %d blocks to %s\n", len(blocks), ref)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/manifest"
+)
+
+// runStatus handles `notionsync status`, comparing the pages recorded by the last pull with the
+// files on disk and with the pages in Notion.
+func runStatus(flags *flag.FlagSet, args []string) error {
+	tokens := addTokenFlags(flags)
+	outputDir := flags.String("dir", "notion-notes", "Directory the pages were pulled into")
+	all := flags.Bool("all", false, "List unchanged pages as well")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(*outputDir)
+	if err != nil {
+		return err
+	}
+	if len(m.Pages) == 0 {
+		return fmt.Errorf("no pages have been pulled into %s", *outputDir)
+	}
+	bearerToken, err := tokens.resolve()
+	if err != nil {
+		return err
+	}
+	apiClient := newAPIClient()
+
+	counts := make(map[manifest.State]int)
+	for _, pageID := range m.IDs() {
+		entry := m.Pages[pageID]
+		page, err := api.FetchPage(apiClient, pageID, bearerToken)
+		if err != nil {
+			slog.Error("error fetching page", "path", entry.Path, "err", err)
+			continue
+		}
+		lastEdited, err := time.Parse(time.RFC3339, page.LastEditedTime)
+		if err != nil {
+			slog.Error("error reading last edited time", "path", entry.Path, "err", err)
+			continue
+		}
+		state, err := entry.Compare(*outputDir, lastEdited)
+		if err != nil {
+			slog.Error("error reading page", "path", entry.Path, "err", err)
+			continue
+		}
+
+		counts[state]++
+		if state != manifest.Unchanged || *all {
+			fmt.Printf("%-18s %s\n", state, entry.Path)
+		}
+	}
+
+	fmt.Printf("%d unchanged, %d modified, %d changed in Notion, %d conflicts, %d deleted\n",
+		counts[manifest.Unchanged], counts[manifest.LocalChanges], counts[manifest.RemoteChanges],
+		counts[manifest.BothChanged], counts[manifest.DeletedLocally])
+	return nil
+}
@@ -0,0 +1,216 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect controls the syntax choices made when writing markdown, so the output can
+// be matched to a renderer or a markdownlint configuration.
+type Dialect struct {
+	Name string
+	// Bullet is the marker for bulleted list items, to-dos and page links: "-", "*" or "+".
+	Bullet string
+	// Emphasis and Strong are the markers for italic and bold text, e.g. "*" and "**" or "_" and "__".
+	Emphasis string
+	Strong   string
+	// Strikethrough is the marker for struck-through text. It is written as <del> when empty.
+	Strikethrough string
+	// Underline writes underlined text as <u>, markdown has no syntax for it so it is dropped otherwise.
+	Underline bool
+	// HardBreak ends a line that is followed by a line break within the same block: "\\", "  " or "<br>".
+	HardBreak string
+	// Wrap is the column that paragraphs, list items and quotes are wrapped at. Zero disables wrapping.
+	Wrap int
+	// Fence opens and closes code blocks: "```" or "~~~".
+	Fence string
+	// FenceLanguage is written for code blocks that have no language.
+	FenceLanguage string
+	// BlankLines separates blocks with a blank line. Items of the same list are kept together.
+	BlankLines bool
+}
+
+var (
+	// GFM is GitHub Flavored Markdown, and the dialect used when none is chosen.
+	GFM = Dialect{
+		Name:          "gfm",
+		Bullet:        "-",
+		Emphasis:      "*",
+		Strong:        "**",
+		Strikethrough: "~~",
+		HardBreak:     "\\",
+		Fence:         "```",
+	}
+	// CommonMark sticks to the CommonMark spec, using inline HTML for underline and strikethrough.
+	CommonMark = Dialect{
+		Name:       "commonmark",
+		Bullet:     "-",
+		Emphasis:   "*",
+		Strong:     "**",
+		Underline:  true,
+		HardBreak:  "\\",
+		Fence:      "```",
+		BlankLines: true,
+	}
+	// Strict passes markdownlint's default rules: consistent markers, no inline HTML,
+	// lines wrapped at 80 columns, blank lines around blocks and a language on every code block.
+	Strict = Dialect{
+		Name:          "strict",
+		Bullet:        "-",
+		Emphasis:      "_",
+		Strong:        "**",
+		Strikethrough: "~~",
+		HardBreak:     "\\",
+		Wrap:          80,
+		Fence:         "```",
+		FenceLanguage: "text",
+		BlankLines:    true,
+	}
+)
+
+// ParseDialect returns the Dialect with the given name. An empty name is GFM.
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", GFM.Name:
+		return GFM, nil
+	case CommonMark.Name:
+		return CommonMark, nil
+	case Strict.Name:
+		return Strict, nil
+	}
+	return Dialect{}, fmt.Errorf("unknown dialect %q", name)
+}
+
+// dialect returns the dialect to write in, defaulting to GFM.
+func (opts Options) dialect() Dialect {
+	if opts.Dialect.Name == "" {
+		return GFM
+	}
+	return opts.Dialect
+}
+
+// codeBlock renders a fenced code block. The fence is lengthened when the code itself contains it.
+func (d Dialect) codeBlock(code, language string) string {
+	fence := d.Fence
+	for strings.Contains(code, fence) {
+		fence += fence[:1]
+	}
+	if language == "" || language == "plain text" {
+		language = d.FenceLanguage
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// formatLines lays out the text of a block after its prefix. Line breaks within the text become
+// hard breaks, continuation lines are indented so that they stay part of the block, and no line
+// is left starting with something that would be read as another block.
+func (d Dialect) formatLines(prefix, continuation, text string, wrap bool) string {
+	firstLine := prefix[strings.LastIndex(prefix, "\n")+1:]
+
+	var lines []string
+	paragraphLines := strings.Split(text, "\n")
+	for i, paragraphLine := range paragraphLines {
+		paragraphLine = strings.TrimRight(escapeLineStart(paragraphLine), " \t")
+		indent := utf8.RuneCountInString(continuation)
+		if i == 0 {
+			indent = utf8.RuneCountInString(firstLine)
+		}
+		if wrap && d.Wrap > 0 {
+			lines = append(lines, wrapLine(paragraphLine, d.Wrap-indent, d.Wrap-utf8.RuneCountInString(continuation))...)
+		} else {
+			lines = append(lines, paragraphLine)
+		}
+		if i < len(paragraphLines)-1 {
+			lines[len(lines)-1] += d.HardBreak
+		}
+	}
+	return prefix + strings.Join(lines, "\n"+continuation)
+}
+
+// lineStartPattern matches words that change the meaning of a line when they start it.
+var lineStartPattern = regexp.MustCompile(`^(#{1,6}|[-+*]|\d{1,9}[.)]|>.*|=+|-+)$`)
+
+// wrapLine breaks text at spaces so that the first line fits in firstWidth columns and the rest in width.
+// Widths are counted in characters rather than bytes, as markdownlint does.
+// Code spans, wikilinks, template tags and HTML are never broken, and a line never starts with a word
+// that would turn it into a list item, heading or quote.
+func wrapLine(text string, firstWidth, width int) []string {
+	words := splitWords(text)
+	if len(words) == 0 {
+		return []string{text}
+	}
+
+	var lines []string
+	line := words[0]
+	lineWidth := utf8.RuneCountInString(line)
+	limit := firstWidth
+	for _, word := range words[1:] {
+		wordWidth := utf8.RuneCountInString(word)
+		if lineWidth+1+wordWidth > limit && !lineStartPattern.MatchString(word) {
+			lines = append(lines, line)
+			line, lineWidth = word, wordWidth
+			limit = width
+			continue
+		}
+		line += " " + word
+		lineWidth += 1 + wordWidth
+	}
+	return append(lines, line)
+}
+
+// splitWords splits text on the spaces that are safe to break a line at.
+func splitWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	closing := ""
+	for i := 0; i < len(text); i++ {
+		switch {
+		case closing != "":
+			if strings.HasPrefix(text[i:], closing) {
+				word.WriteString(closing)
+				i += len(closing) - 1
+				closing = ""
+				continue
+			}
+		case text[i] == '`':
+			ticks := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			closing = strings.Repeat("`", ticks)
+			word.WriteString(closing)
+			i += ticks - 1
+			continue
+		case strings.HasPrefix(text[i:], "[["):
+			closing = "]]"
+		case strings.HasPrefix(text[i:], "{{"):
+			closing = "}}"
+		case strings.HasPrefix(text[i:], "{%"):
+			closing = "%}"
+		case text[i] == '<' && i+1 < len(text) && (text[i+1] == '/' || unicode.IsLetter(rune(text[i+1]))):
+			closing = ">"
+		case text[i] == ' ':
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteByte(text[i])
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// listKind groups the block types that are written as items of the same list.
+func listKind(blockType string) string {
+	switch blockType {
+	case "bulleted_list_item", "to_do", "child_page", "link_to_page", "bookmark":
+		return "bulleted"
+	case "numbered_list_item":
+		return "numbered"
+	}
+	return ""
+}
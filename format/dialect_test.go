@@ -0,0 +1,162 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"", "gfm", false},
+		{"GFM", "gfm", false},
+		{"commonmark", "commonmark", false},
+		{"strict", "strict", false},
+		{"markdown-extra", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDialect(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDialect(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got.Name != tt.expected {
+				t.Errorf("ParseDialect(%q) = %q, want %q", tt.input, got.Name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyAnnotationsWithDialect(t *testing.T) {
+	tests := []struct {
+		name        string
+		dialect     Dialect
+		annotations api.Annotations
+		expected    string
+	}{
+		{"strict bold and italic", Strict, api.Annotations{Bold: true, Italic: true}, "**_Test_**"},
+		{"strict italic", Strict, api.Annotations{Italic: true}, "_Test_"},
+		{"commonmark strikethrough", CommonMark, api.Annotations{Strikethrough: true}, "<del>Test</del>"},
+		{"commonmark underline", CommonMark, api.Annotations{Underline: true}, "<u>Test</u>"},
+		{"gfm drops underline", GFM, api.Annotations{Underline: true}, "Test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := api.RichText{Text: api.Text{Content: "Test"}, Annotations: tt.annotations}
+			if got := applyAnnotationsToContent(rt, Options{Dialect: tt.dialect}); got != tt.expected {
+				t.Errorf("applyAnnotationsToContent() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	if got := GFM.codeBlock("fmt.Println()", "go"); got != "```go\nfmt.Println()\n```" {
+		t.Errorf("codeBlock() = %q", got)
+	}
+	if got := Strict.codeBlock("plain", "plain text"); got != "```text\nplain\n```" {
+		t.Errorf("codeBlock() with no language = %q", got)
+	}
+	tilde := GFM
+	tilde.Fence = "~~~"
+	if got := tilde.codeBlock("a ~~~ b", ""); got != "~~~~\na ~~~ b\n~~~~" {
+		t.Errorf("codeBlock() containing the fence = %q", got)
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		width    int
+		expected []string
+	}{
+		{
+			name:     "breaks at spaces",
+			text:     "one two three four",
+			width:    9,
+			expected: []string{"one two", "three", "four"},
+		},
+		{
+			name:     "keeps code spans together",
+			text:     "run `go test ./...` now",
+			width:    8,
+			expected: []string{"run", "`go test ./...`", "now"},
+		},
+		{
+			name:     "counts characters rather than bytes",
+			text:     "naïve café déjà vu 日本語の文章 🎉🎉",
+			width:    12,
+			expected: []string{"naïve café", "déjà vu", "日本語の文章 🎉🎉"},
+		},
+		{
+			name:     "does not start a line with a list marker",
+			text:     "chapter 1. intro",
+			width:    8,
+			expected: []string{"chapter 1.", "intro"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapLine(tt.text, tt.width, tt.width); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("wrapLine() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatLinesHardBreaks(t *testing.T) {
+	got := GFM.formatLines("- ", "  ", "first\nsecond", true)
+	if got != "- first\\\n  second" {
+		t.Errorf("formatLines() = %q", got)
+	}
+}
+
+func TestWriteBlocksToMarkdownStrict(t *testing.T) {
+	paragraph := func(text string) api.Block {
+		return api.Block{Type: "paragraph", Paragraph: &api.Paragraph{RichText: []api.RichText{{Text: api.Text{Content: text}}}}}
+	}
+	bullet := func(text string) api.Block {
+		return api.Block{Type: "bulleted_list_item", Bulleted: &api.ListItem{RichText: []api.RichText{{Text: api.Text{Content: text}}}}}
+	}
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			paragraph("First paragraph that is long enough to need wrapping once it goes past eighty columns."),
+			bullet("one"),
+			bullet("two"),
+			{Type: "code", Code: &api.Code{RichText: []api.RichText{{Text: api.Text{Content: "echo hi"}}}}},
+		},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "strict.md")
+	if err := WriteBlocksToMarkdown(results, outputPath, Page{Name: "strict"}, map[string]string{}, Options{Dialect: Strict}); err != nil {
+		t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	expected := "# Strict\n\n" +
+		"First paragraph that is long enough to need wrapping once it goes past eighty\n" +
+		"columns.\n" +
+		"\n" +
+		"- one\n" +
+		"- two\n" +
+		"\n" +
+		"```text\necho hi\n```\n"
+	if string(content) != expected {
+		t.Errorf("Unexpected content:\n%q\nwant\n%q", content, expected)
+	}
+}
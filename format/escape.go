@@ -0,0 +1,129 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// entityPattern matches the start of an HTML entity, which markdown would decode.
+var entityPattern = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+
+// escapeText escapes the characters in text content that markdown would otherwise read as formatting.
+// Underscores inside words are left alone as they never start emphasis.
+func escapeText(text string, opts Options) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '\\', '`', '*', '[', ']', '<', '~':
+			sb.WriteByte('\\')
+		case '_':
+			if !isIntraword(text, i) {
+				sb.WriteByte('\\')
+			}
+		case '&':
+			if entityPattern.MatchString(text[i:]) {
+				sb.WriteString("&amp;")
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	escaped := sb.String()
+	// Hugo and Jekyll run pages through a template engine before rendering the markdown
+	if opts.Flavour == Hugo || opts.Flavour == Jekyll {
+		escaped = strings.ReplaceAll(escaped, "{{", "&#123;{")
+		escaped = strings.ReplaceAll(escaped, "{%", "&#123;%")
+	}
+	return escaped
+}
+
+// isIntraword reports whether the byte at i is surrounded by letters or digits.
+func isIntraword(text string, i int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:i])
+	after, _ := utf8.DecodeRuneInString(text[i+1:])
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return isWordRune(before) && isWordRune(after)
+}
+
+// escapeURL percent-encodes the characters that would end a link destination early.
+func escapeURL(url string) string {
+	return strings.NewReplacer(
+		" ", "%20",
+		"(", "%28",
+		")", "%29",
+		"<", "%3C",
+		">", "%3E",
+	).Replace(url)
+}
+
+// blockStartPattern matches the start of a line that markdown would read as a heading,
+// list item, quote, setext underline or thematic break.
+var blockStartPattern = regexp.MustCompile(`^(#{1,6}([ \t]|$)|[-+*]([ \t]|$)|>|\d{1,9}[.)]([ \t]|$)|=+[ \t]*$|-+[ \t]*$)`)
+
+// escapeLineStart stops a line of text from being read as the start of another block.
+// Leading whitespace is dropped, as markdown ignores it or turns the line into an indented code block.
+func escapeLineStart(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	match := blockStartPattern.FindString(line)
+	if match == "" {
+		return line
+	}
+	// An ordered list marker is escaped at its delimiter, e.g. 1\.
+	if digits := strings.TrimLeft(match, "0123456789"); len(digits) < len(match) {
+		split := len(match) - len(digits)
+		return line[:split] + "\\" + line[split:]
+	}
+	return "\\" + line
+}
+
+// codeSpan wraps text in enough backticks that backticks within it do not end the span early.
+func codeSpan(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	fence := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// protectCode stops the template engine that Hugo and Jekyll run pages through from running the
+// template tags in rendered code, which cannot be escaped like text as code is written as it is.
+// Hugo only runs shortcodes, which are commented out, and Jekyll leaves anything between raw tags alone.
+func protectCode(code string, opts Options) string {
+	switch opts.Flavour {
+	case Hugo:
+		if strings.Contains(code, "{{<") || strings.Contains(code, "{{%") {
+			return strings.NewReplacer("{{<", "{{</*", ">}}", "*/>}}", "{{%", "{{%/*", "%}}", "*/%}}").Replace(code)
+		}
+	case Jekyll:
+		if strings.Contains(code, "{{") || strings.Contains(code, "{%") {
+			return "{% raw %}" + code + "{% endraw %}"
+		}
+	}
+	return code
+}
+
+// splitSurroundingSpace separates the leading and trailing whitespace from text, so that
+// annotations can be applied to the text alone. Markdown does not allow emphasis markers next to whitespace.
+func splitSurroundingSpace(text string) (leading, core, trailing string) {
+	core = strings.TrimLeftFunc(text, unicode.IsSpace)
+	leading = text[:len(text)-len(core)]
+	core = strings.TrimRightFunc(core, unicode.IsSpace)
+	trailing = text[len(leading)+len(core):]
+	return leading, core, trailing
+}
@@ -0,0 +1,187 @@
+package format
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		flavour  Flavour
+		expected string
+	}{
+		{"emphasis markers", "*a* _b_", Markdown, `\*a\* \_b\_`},
+		{"underscores inside words", "snake_case_name", Markdown, "snake_case_name"},
+		{"brackets and ticks", "[x] `y`", Markdown, "\\[x\\] \\`y\\`"},
+		{"entities", "&copy; & co", Markdown, "&amp;copy; & co"},
+		{"template tags in hugo", "{{ .Title }} {% raw %}", Hugo, "&#123;{ .Title }} &#123;% raw %}"},
+		{"template tags in markdown", "{{ .Title }}", Markdown, "{{ .Title }}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeText(tt.input, Options{Flavour: tt.flavour}); got != tt.expected {
+				t.Errorf("escapeText(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEscapeLineStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"# heading", `\# heading`},
+		{"#hashtag", "#hashtag"},
+		{"12. item", `12\. item`},
+		{"3.5 degrees", "3.5 degrees"},
+		{"- item", `\- item`},
+		{"-5 degrees", "-5 degrees"},
+		{"> quote", `\> quote`},
+		{"===", `\===`},
+		{"    indented", "indented"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := escapeLineStart(tt.input); got != tt.expected {
+				t.Errorf("escapeLineStart(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEscapeURL(t *testing.T) {
+	got := escapeURL("https://example.com/Go_(language) page")
+	if got != "https://example.com/Go_%28language%29%20page" {
+		t.Errorf("escapeURL() = %q", got)
+	}
+}
+
+func TestPageLinkTitles(t *testing.T) {
+	tests := []struct {
+		title    string
+		flavour  Flavour
+		expected string
+	}{
+		{"Release Notes", Obsidian, "[[release-notes|Release Notes]]"},
+		{"Yes | No", Obsidian, `[Yes | No](yes-|-no.md)`},
+		{"[Draft] Plan", Obsidian, `[\[Draft\] Plan]([draft]-plan.md)`},
+		{"C# tips", Obsidian, "[C# tips](c%23-tips.md)"},
+		{"C# tips", Markdown, "[C# tips](c%23-tips.md)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.flavour)+" "+tt.title, func(t *testing.T) {
+			if got := tt.flavour.pageLink(tt.title, ""); got != tt.expected {
+				t.Errorf("pageLink(%q) = %q, want %q", tt.title, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodeSpan(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "`plain`"},
+		{"a `b` c", "``a `b` c``"},
+		{"``x", "``` ``x ```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := codeSpan(tt.input); got != tt.expected {
+				t.Errorf("codeSpan(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProtectCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		flavour  Flavour
+		expected string
+	}{
+		{"shortcode in hugo", "`{{< youtube id >}}`", Hugo, "`{{</* youtube id */>}}`"},
+		{"markdown shortcode in hugo", "`{{% note %}}`", Hugo, "`{{%/* note */%}}`"},
+		{"template in hugo", "`{{ .Title }}`", Hugo, "`{{ .Title }}`"},
+		{"template in jekyll", "`{{ page.title }}`", Jekyll, "{% raw %}`{{ page.title }}`{% endraw %}"},
+		{"tag in jekyll", "```\n{% if x %}\n```", Jekyll, "{% raw %}```\n{% if x %}\n```{% endraw %}"},
+		{"no tags in jekyll", "`x`", Jekyll, "`x`"},
+		{"template in markdown", "`{{ .Title }}`", Markdown, "`{{ .Title }}`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := protectCode(tt.code, Options{Flavour: tt.flavour}); got != tt.expected {
+				t.Errorf("protectCode(%q) = %q, want %q", tt.code, got, tt.expected)
+			}
+		})
+	}
+
+	code := []api.RichText{{Type: "text", Text: api.Text{Content: "{{ page.title }}"}, Annotations: api.Annotations{Code: true}}}
+	if got := renderRichText(code, Options{Flavour: Jekyll}); got != "{% raw %}`{{ page.title }}`{% endraw %}" {
+		t.Errorf("Expected the code span to be left alone by Liquid, got %q", got)
+	}
+}
+
+// TestEscapingGolden renders each testdata/escaping/*.json page and compares it with the .md file next to it.
+// Run `go test ./format -update` to regenerate the golden files after an intended change in output.
+func TestEscapingGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "escaping", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list test cases: %v", err)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatalf("Failed to read test case: %v", err)
+			}
+			var results api.ResultsWrapper
+			if err := json.Unmarshal(data, &results); err != nil {
+				t.Fatalf("Failed to parse test case: %v", err)
+			}
+
+			outputPath := filepath.Join(t.TempDir(), name+".md")
+			if err := WriteBlocksToMarkdown(&results, outputPath, Page{Name: name}, map[string]string{}, Options{}); err != nil {
+				t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+
+			goldenPath := strings.TrimSuffix(input, ".json") + ".md"
+			if *update {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Output does not match %s:\n%s\nwant:\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/progress"
+)
+
+// Flavour selects the variant of markdown that pages are written in, and for
+// static site generators the directory layout they are written to.
+type Flavour string
+
+const (
+	// Markdown writes plain markdown with relative links to the other exported files.
+	Markdown Flavour = "markdown"
+	// Obsidian writes [[wikilinks]], ![[embeds]], callouts and front matter for use in an Obsidian vault.
+	Obsidian Flavour = "obsidian"
+	// Hugo writes pages into content/, with pages that have child pages becoming sections with an _index.md.
+	Hugo Flavour = "hugo"
+	// Jekyll writes pages as posts into _posts/, named after the date the page was created.
+	Jekyll Flavour = "jekyll"
+	// Docusaurus writes pages into docs/, with pages that have child pages becoming categories.
+	Docusaurus Flavour = "docusaurus"
+)
+
+// ParseFlavour returns the Flavour with the given name. An empty name is the plain Markdown flavour.
+func ParseFlavour(name string) (Flavour, error) {
+	switch flavour := Flavour(strings.ToLower(name)); flavour {
+	case "":
+		return Markdown, nil
+	case Markdown, Obsidian, Hugo, Jekyll, Docusaurus:
+		return flavour, nil
+	}
+	return "", fmt.Errorf("unknown format %q", name)
+}
+
+// Options controls how pages are rendered to markdown.
+type Options struct {
+	Flavour Flavour
+	Dialect Dialect
+	Layout  Layout
+	// AssetDir is the directory that images and files are downloaded into.
+	// Assets are linked to their Notion URL instead when it is empty.
+	AssetDir string
+	// Offline keeps the assets already in AssetDir rather than downloading them, and links to
+	// those that are missing by their URL.
+	Offline bool
+	// Sink receives the files of each page instead of the file system when it is not nil.
+	// Assets are not downloaded then, but are still linked to where they would be.
+	Sink Sink
+	// Stats counts the files written to disk when it is not nil.
+	Stats *WriteStats
+	// Events receives an event for every page written.
+	Events progress.Listener
+}
+
+// Page holds the metadata of the page being written.
+type Page struct {
+	ID         string
+	Name       string // file name without the .md extension
+	Title      string
+	Tags       []string
+	Created    string // RFC 3339 timestamps, empty when the page object was not fetched
+	LastEdited string
+	Position   int // 1-based position among the parent's child pages, 0 for top level pages
+	// RootPath leads from the page's directory back to the directory linked pages are written to, see RootPath.
+	RootPath string
+}
+
+// displayTitle is the page's title, or a title made from its file name when the page object was not fetched.
+func (p Page) displayTitle() string {
+	if p.Title == "" {
+		return toTitleCase(p.Name)
+	}
+	return p.Title
+}
+
+// NewPage builds the Page metadata from a Notion page object. Tags are collected from every multi-select property.
+func NewPage(name string, notionPage *api.Page) Page {
+	page := Page{
+		ID:         notionPage.ID,
+		Name:       name,
+		Title:      notionPage.Title(),
+		Created:    notionPage.CreatedTime,
+		LastEdited: notionPage.LastEditedTime,
+	}
+	for _, property := range notionPage.Properties {
+		for _, option := range property.MultiSelect {
+			page.Tags = append(page.Tags, option.Name)
+		}
+	}
+	return page
+}
+
+// ContentDir is the directory, relative to the output directory, that the static site generator reads pages from.
+func (f Flavour) ContentDir() string {
+	switch f {
+	case Hugo:
+		return "content"
+	case Jekyll:
+		return "_posts"
+	case Docusaurus:
+		return "docs"
+	}
+	return ""
+}
+
+// PagePath returns the file a page is written to. dir is the directory the page belongs in,
+// and hasChildPages whether the page becomes a section that its child pages are nested under.
+func (f Flavour) PagePath(dir string, page Page, hasChildPages bool) string {
+	switch {
+	case f == Hugo && hasChildPages:
+		return filepath.Join(dir, page.Name, "_index.md")
+	case f == Docusaurus && hasChildPages:
+		return filepath.Join(dir, page.Name, "index.md")
+	case f == Jekyll:
+		if date := postDate(page); date != "" {
+			return filepath.Join(dir, date+"-"+page.Name+".md")
+		}
+	}
+	return filepath.Join(dir, page.Name+".md")
+}
+
+// postDate returns the YYYY-MM-DD date that Jekyll expects at the start of a post's file name, taken
+// from when the page was created, or else last edited. It is empty when neither is known, rather than
+// today's date, so that the file keeps its name from one run to the next.
+func postDate(page Page) string {
+	for _, timestamp := range []string{page.Created, page.LastEdited} {
+		if date, err := time.Parse(time.RFC3339, timestamp); err == nil {
+			return date.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// hasFrontMatter reports whether pages in this flavour start with a front matter block.
+func (f Flavour) hasFrontMatter() bool {
+	return f != Markdown && f != ""
+}
+
+// writesTitleHeading reports whether the page title is written as a heading.
+// Static site generators render the title from the front matter instead.
+func (f Flavour) writesTitleHeading() bool {
+	return f != Hugo && f != Jekyll && f != Docusaurus
+}
+
+// frontMatter renders the front matter block for a page, or an empty string if the flavour has none.
+func (f Flavour) frontMatter(page Page, title string) string {
+	if !f.hasFrontMatter() {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	switch f {
+	case Obsidian:
+		sb.WriteString("aliases:\n")
+		sb.WriteString("  - " + strconv.Quote(title) + "\n")
+	case Hugo:
+		sb.WriteString("title: " + strconv.Quote(title) + "\n")
+		if page.Created != "" {
+			sb.WriteString("date: " + page.Created + "\n")
+		}
+		if page.LastEdited != "" {
+			sb.WriteString("lastmod: " + page.LastEdited + "\n")
+		}
+		if page.Position > 0 {
+			sb.WriteString(fmt.Sprintf("weight: %d\n", page.Position))
+		}
+	case Jekyll:
+		sb.WriteString("layout: post\n")
+		sb.WriteString("title: " + strconv.Quote(title) + "\n")
+		if page.Created != "" {
+			sb.WriteString("date: " + page.Created + "\n")
+		}
+		sb.WriteString("permalink: /" + page.Name + "/\n")
+	case Docusaurus:
+		sb.WriteString("title: " + strconv.Quote(title) + "\n")
+		sb.WriteString("slug: /" + page.Name + "\n")
+		if page.Position > 0 {
+			sb.WriteString(fmt.Sprintf("sidebar_position: %d\n", page.Position))
+		}
+	}
+	if len(page.Tags) > 0 {
+		sb.WriteString("tags:\n")
+		for _, tag := range page.Tags {
+			// Obsidian tags cannot contain spaces, and the static site generators use them in URLs
+			sb.WriteString("  - " + strconv.Quote(strings.ReplaceAll(tag, " ", "-")) + "\n")
+		}
+	}
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
+
+// writeSectionFiles writes the files a static site generator needs next to a page that has child pages.
+func (f Flavour) writeSectionFiles(outputPath string, page Page, title string, opts Options) error {
+	if f != Docusaurus || filepath.Base(outputPath) != "index.md" {
+		return nil
+	}
+
+	category := struct {
+		Label    string `json:"label"`
+		Position int    `json:"position,omitempty"`
+	}{Label: title, Position: page.Position}
+	data, err := json.MarshalIndent(category, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding category file: %w", err)
+	}
+	categoryPath := filepath.Join(filepath.Dir(outputPath), "_category_.json")
+	if err := opts.writeFile(categoryPath, append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing category file: %w", err)
+	}
+	return nil
+}
+
+// pageLink renders a link to another exported page. dir is the directory of the other page
+// relative to this one, which only matters for plain markdown's relative links.
+func (f Flavour) pageLink(title, dir string) string {
+	name := strcase.ToKebab(title)
+	// A wikilink ends at | or ]] and points at a heading from #, so titles with them get a markdown link instead
+	if f == Obsidian && !strings.ContainsAny(title, "|[]#") {
+		return fmt.Sprintf("[[%s|%s]]", name, title)
+	}
+
+	title = escapeText(title, Options{Flavour: f})
+	switch f {
+	case Hugo:
+		return fmt.Sprintf(`[%s]({{< ref "%s" >}})`, title, name)
+	case Jekyll:
+		return fmt.Sprintf("[%s]({{ '/%s/' | relative_url }})", title, name)
+	case Docusaurus:
+		return fmt.Sprintf("[%s](/docs/%s)", title, name)
+	}
+	// File names keep the # of their title, which would otherwise start the link's fragment
+	return fmt.Sprintf("[%s](%s.md)", title, strings.ReplaceAll(escapeURL(path.Join(dir, name)), "#", "%23"))
+}
+
+// embed renders an image or file. assetName is the name of the downloaded file, or empty if it was not downloaded.
+func (f Flavour) embed(assetName, url, caption string, image bool) string {
+	if f == Obsidian && assetName != "" {
+		return "![[" + assetName + "]]"
+	}
+	if caption == "" && !image {
+		caption = url
+	}
+	caption = escapeText(caption, Options{Flavour: f})
+	if image {
+		return fmt.Sprintf("![%s](%s)", caption, escapeURL(url))
+	}
+	return fmt.Sprintf("[%s](%s)", caption, escapeURL(url))
+}
+
+// calloutPrefix renders the line that opens a callout block.
+func (f Flavour) calloutPrefix(callout *api.Callout) string {
+	if f == Obsidian {
+		return "> [!" + calloutType(callout.Color) + "]\n> "
+	}
+	if callout.Icon != nil && callout.Icon.Emoji != "" {
+		return "> " + callout.Icon.Emoji + " "
+	}
+	return "> "
+}
+
+// calloutType maps the colour of a Notion callout onto the closest Obsidian callout type.
+func calloutType(color string) string {
+	switch strings.TrimSuffix(color, "_background") {
+	case "red":
+		return "danger"
+	case "orange", "yellow":
+		return "warning"
+	case "green":
+		return "success"
+	case "blue":
+		return "info"
+	case "purple":
+		return "tip"
+	}
+	return "note"
+}
@@ -0,0 +1,267 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestParseFlavour(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Flavour
+		wantErr  bool
+	}{
+		{"", Markdown, false},
+		{"markdown", Markdown, false},
+		{"Obsidian", Obsidian, false},
+		{"word", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFlavour(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFlavour(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseFlavour(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	notionPage := &api.Page{
+		ID: "page-id",
+		Properties: map[string]api.Property{
+			"Name": {Type: "title", Title: []api.RichText{{PlainText: "Weekly Sync"}}},
+			"Tags": {Type: "multi_select", MultiSelect: []api.SelectOption{{Name: "meetings"}, {Name: "team a"}}},
+		},
+	}
+
+	page := NewPage("weekly-sync", notionPage)
+	if page.Title != "Weekly Sync" {
+		t.Errorf("Expected title %q, got %q", "Weekly Sync", page.Title)
+	}
+	if len(page.Tags) != 2 || page.Tags[0] != "meetings" || page.Tags[1] != "team a" {
+		t.Errorf("Expected tags [meetings team a], got %v", page.Tags)
+	}
+}
+
+func TestApplyAnnotationsToPageMention(t *testing.T) {
+	href := "https://www.notion.so/abc123"
+	rt := api.RichText{
+		Type:      "mention",
+		Mention:   &api.Mention{Type: "page", Page: &api.PageReference{ID: "abc123"}},
+		PlainText: "Project Plan",
+		Href:      &href,
+	}
+
+	if got := applyAnnotationsToContent(rt, Options{Flavour: Obsidian}); got != "[[project-plan|Project Plan]]" {
+		t.Errorf("Obsidian mention = %q, want %q", got, "[[project-plan|Project Plan]]")
+	}
+	if got := applyAnnotationsToContent(rt, Options{Flavour: Markdown}); got != "[Project Plan](https://www.notion.so/abc123)" {
+		t.Errorf("Markdown mention = %q, want %q", got, "[Project Plan](https://www.notion.so/abc123)")
+	}
+}
+
+func TestWriteBlocksToObsidianMarkdown(t *testing.T) {
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{
+				ID:        "1",
+				Type:      "child_page",
+				ChildPage: &api.ChildPage{Title: "Sub Page"},
+			},
+			{
+				ID:         "2",
+				Type:       "link_to_page",
+				LinkToPage: &api.LinkToPage{Type: "page_id", PageID: "linked"},
+			},
+			{
+				ID:   "3",
+				Type: "callout",
+				Callout: &api.Callout{
+					RichText: []api.RichText{{Text: api.Text{Content: "Watch out"}}},
+					Icon:     &api.Icon{Type: "emoji", Emoji: "⚠️"},
+					Color:    "yellow_background",
+				},
+			},
+			{
+				ID:   "4",
+				Type: "image",
+				Image: &api.File{
+					Type:     "external",
+					External: &api.ExternalFile{URL: "https://example.com/diagram.png"},
+				},
+			},
+		},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "weekly-sync.md")
+	page := Page{Name: "weekly-sync", Title: "Weekly Sync", Tags: []string{"team a"}}
+	linkTitles := map[string]string{"linked": "Linked Page"}
+	if err := WriteBlocksToMarkdown(results, outputPath, page, linkTitles, Options{Flavour: Obsidian}); err != nil {
+		t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	expectedContents := []string{
+		"---\naliases:\n  - \"Weekly Sync\"\ntags:\n  - \"team-a\"\n---\n\n# Weekly Sync\n\n",
+		"- [[sub-page|Sub Page]]\n",
+		"- [[linked-page|Linked Page]]\n",
+		"> [!warning]\n> Watch out\n",
+		// Without an asset directory the image is not downloaded
+		"![](https://example.com/diagram.png)\n",
+	}
+
+	for _, ec := range expectedContents {
+		if !strings.Contains(string(content), ec) {
+			t.Errorf("File content does not contain expected text: %q\n%s", ec, content)
+		}
+	}
+}
+
+func TestPagePath(t *testing.T) {
+	page := Page{Name: "release-notes", Created: "2024-03-05T10:00:00.000Z"}
+	tests := []struct {
+		flavour       Flavour
+		hasChildPages bool
+		expected      string
+	}{
+		{Markdown, true, filepath.Join("out", "release-notes.md")},
+		{Hugo, false, filepath.Join("out", "release-notes.md")},
+		{Hugo, true, filepath.Join("out", "release-notes", "_index.md")},
+		{Jekyll, true, filepath.Join("out", "2024-03-05-release-notes.md")},
+		{Docusaurus, true, filepath.Join("out", "release-notes", "index.md")},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.flavour), func(t *testing.T) {
+			if got := tt.flavour.PagePath("out", page, tt.hasChildPages); got != tt.expected {
+				t.Errorf("PagePath() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPostDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     Page
+		expected string
+	}{
+		{"created", Page{Created: "2024-03-05T10:00:00.000Z", LastEdited: "2024-04-01T10:00:00.000Z"}, "2024-03-05"},
+		{"last edited", Page{LastEdited: "2024-04-01T10:00:00.000Z"}, "2024-04-01"},
+		{"unknown", Page{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postDate(tt.page); got != tt.expected {
+				t.Errorf("postDate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	// Without a date the post keeps the same name on every run
+	if got := Jekyll.PagePath("out", Page{Name: "draft"}, false); got != filepath.Join("out", "draft.md") {
+		t.Errorf("PagePath() = %q, want %q", got, filepath.Join("out", "draft.md"))
+	}
+}
+
+func TestStaticSiteFrontMatter(t *testing.T) {
+	page := Page{
+		Name:       "release-notes",
+		Tags:       []string{"news"},
+		Created:    "2024-03-05T10:00:00.000Z",
+		LastEdited: "2024-03-06T10:00:00.000Z",
+		Position:   2,
+	}
+	tests := []struct {
+		flavour  Flavour
+		expected string
+	}{
+		{Hugo, "---\ntitle: \"Release Notes\"\ndate: 2024-03-05T10:00:00.000Z\nlastmod: 2024-03-06T10:00:00.000Z\nweight: 2\ntags:\n  - \"news\"\n---\n\n"},
+		{Jekyll, "---\nlayout: post\ntitle: \"Release Notes\"\ndate: 2024-03-05T10:00:00.000Z\npermalink: /release-notes/\ntags:\n  - \"news\"\n---\n\n"},
+		{Docusaurus, "---\ntitle: \"Release Notes\"\nslug: /release-notes\nsidebar_position: 2\ntags:\n  - \"news\"\n---\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.flavour), func(t *testing.T) {
+			if got := tt.flavour.frontMatter(page, "Release Notes"); got != tt.expected {
+				t.Errorf("frontMatter() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStaticSitePageLinks(t *testing.T) {
+	tests := []struct {
+		flavour  Flavour
+		expected string
+	}{
+		{Markdown, "[Release Notes](release-notes.md)"},
+		{Hugo, `[Release Notes]({{< ref "release-notes" >}})`},
+		{Jekyll, "[Release Notes]({{ '/release-notes/' | relative_url }})"},
+		{Docusaurus, "[Release Notes](/docs/release-notes)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.flavour), func(t *testing.T) {
+			if got := tt.flavour.pageLink("Release Notes", ""); got != tt.expected {
+				t.Errorf("pageLink() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessBlocksDocusaurusLayout(t *testing.T) {
+	mockAPI := &MockNotionAPI{}
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{ID: "child", Type: "child_page", HasChildren: true, ChildPage: &api.ChildPage{Title: "Getting Started"}},
+		},
+	}
+
+	contentDir := filepath.Join(t.TempDir(), Docusaurus.ContentDir())
+	page := Page{ID: "root", Name: "guide"}
+	outputPath := Docusaurus.PagePath(contentDir, page, HasChildPages(results))
+	pages, err := ProcessBlocks(results, outputPath, page, mockAPI, "test-token", Options{Flavour: Docusaurus})
+	if err != nil {
+		t.Fatalf("ProcessBlocks returned an error: %v", err)
+	}
+
+	if outputPath != filepath.Join(contentDir, "guide", "index.md") {
+		t.Errorf("Expected the page to be written as a section index, got %s", outputPath)
+	}
+	category, err := os.ReadFile(filepath.Join(contentDir, "guide", "_category_.json"))
+	if err != nil {
+		t.Fatalf("Failed to read category file: %v", err)
+	}
+	if !strings.Contains(string(category), `"label": "Guide"`) {
+		t.Errorf("Category file does not contain the label: %s", category)
+	}
+
+	index, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read index page: %v", err)
+	}
+	if !strings.Contains(string(index), "- [Getting Started](/docs/getting-started)\n") {
+		t.Errorf("Index page does not link to the child page: %q", index)
+	}
+
+	expected := []PageRef{{ID: "child", Title: "Getting Started", Position: 1}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("Expected child pages %+v, got %+v", expected, pages)
+	}
+}
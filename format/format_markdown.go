@@ -1,83 +1,233 @@
 package format
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 
-	"github.com/iancoleman/strcase"
 	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
-func applyAnnotationsToContent(rt api.RichText) string {
-	formattedText := rt.Text.Content
+// applyAnnotationsToContent renders a single rich text segment.
+func applyAnnotationsToContent(rt api.RichText, opts Options) string {
+	return renderRichText([]api.RichText{rt}, opts)
+}
+
+func toTitleCase(input string) string {
+	input = strings.ReplaceAll(input, "-", " ")
+	caser := cases.Title(language.English)
+	return caser.String(input)
+}
 
-	if rt.Annotations.Bold && rt.Annotations.Italic {
-		formattedText = "***" + formattedText + "***"
-	} else if rt.Annotations.Bold {
-		formattedText = "**" + formattedText + "**"
-	} else if rt.Annotations.Italic {
-		formattedText = "*" + formattedText + "*"
+// plainText joins the plain text of a rich text array, ignoring annotations.
+func plainText(richText []api.RichText) string {
+	var sb strings.Builder
+	for _, rt := range richText {
+		sb.WriteString(rt.PlainText)
 	}
+	return sb.String()
+}
 
-	if rt.Annotations.Strikethrough {
-		formattedText = "~~" + formattedText + "~~"
+// renderAsset renders an image, file or pdf block. Flavours that embed local files have the asset downloaded into opts.AssetDir first,
+// unless the run is offline.
+func renderAsset(block *api.Block, opts Options) string {
+	var file *api.File
+	switch block.Type {
+	case "image":
+		file = block.Image
+	case "file":
+		file = block.File
+	case "pdf":
+		file = block.PDF
+	}
+	if file == nil {
+		return ""
 	}
 
-	if rt.Annotations.Code {
-		formattedText = "`" + formattedText + "`"
+	fileURL := file.URL()
+	caption := plainText(file.Caption)
+	var assetName string
+	if opts.Flavour == Obsidian && opts.AssetDir != "" {
+		assetName = assetFileName(block, file)
+		if opts.Sink != nil {
+			return opts.Flavour.embed(assetName, fileURL, caption, block.Type == "image")
+		}
+		assetPath := filepath.Join(opts.AssetDir, assetName)
+		if opts.Offline {
+			if fileExists(assetPath) {
+				opts.Stats.Add(atomicfile.Unchanged)
+			} else {
+				slog.Warn("asset was not downloaded before, linking to it instead", "block_id", block.ID)
+				assetName = ""
+			}
+			return opts.Flavour.embed(assetName, fileURL, caption, block.Type == "image")
+		}
+		result, err := fetch.DownloadFile(fileURL, assetPath)
+		switch {
+		case err == nil:
+			opts.Stats.Add(result)
+		case fileExists(assetPath):
+			// The copy from an earlier run is better than a link to a URL that may not work either
+			slog.Warn("error downloading asset, keeping the copy downloaded before", "block_id", block.ID, "err", err)
+			opts.Stats.Add(atomicfile.Unchanged)
+		default:
+			slog.Warn("error downloading asset", "block_id", block.ID, "err", err)
+			assetName = ""
+		}
 	}
+	return opts.Flavour.embed(assetName, fileURL, caption, block.Type == "image")
+}
 
-	// Syntax for underline is not supported in markdown
+// fileExists reports whether a regular file is at path.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
 
-	// Syntax for links
-	if rt.Text.Link != nil && rt.Text.Link.URL != nil {
-		formattedText = "[" + formattedText + "](" + *rt.Text.Link.URL + ")"
+// assetFileName names a downloaded asset after the file in its URL, or the file's name in Notion when
+// the URL has none, prefixed with the start of the block ID so that files with the same name do not
+// overwrite each other. Obsidian only embeds files it can tell the type of from their extension, so
+// images and PDFs without one are given one.
+func assetFileName(block *api.Block, file *api.File) string {
+	prefix := strings.ReplaceAll(block.ID, "-", "")
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+	var base string
+	if parsedURL, err := url.Parse(file.URL()); err == nil {
+		base = path.Base(parsedURL.Path)
+	}
+	if base == "." || base == "/" || base == "" {
+		base = path.Base(file.Name)
 	}
+	if base == "." || base == "/" || base == "" {
+		base = block.Type
+	}
+	if path.Ext(base) == "" {
+		base += defaultAssetExtensions[block.Type]
+	}
+	return prefix + "-" + base
+}
 
-	return formattedText
+// defaultAssetExtensions are the extensions given to assets whose name has none.
+var defaultAssetExtensions = map[string]string{
+	"image": ".png",
+	"pdf":   ".pdf",
 }
 
-func toTitleCase(input string) string {
-	input = strings.ReplaceAll(input, "-", " ")
-	caser := cases.Title(language.English)
-	return caser.String(input)
+// Sink receives the files of a run in place of the file system, so that a run can be previewed without writing anything.
+type Sink interface {
+	WriteFile(path string, data []byte) error
 }
 
-func WriteBlocksToMarkdown(results *api.ResultsWrapper, outputPath string, pageName string, linkTitles map[string]string) error {
-	file, err := os.Create(outputPath)
+// WriteStats counts the files written to disk by what happened to them. It is safe for concurrent use.
+type WriteStats struct {
+	counts [3]atomic.Int64
+}
+
+// Add counts a file.
+func (s *WriteStats) Add(result atomicfile.Result) {
+	if s != nil {
+		s.counts[result].Add(1)
+	}
+}
+
+// Count returns the number of files counted with result.
+func (s *WriteStats) Count(result atomicfile.Result) int {
+	return int(s.counts[result].Load())
+}
+
+// writeFile writes a file to the sink, or to disk when there is none. A file on disk that
+// already has the content is left alone.
+func (o Options) writeFile(path string, data []byte) error {
+	if o.Sink != nil {
+		return o.Sink.WriteFile(path, data)
+	}
+	result, err := atomicfile.WriteIfChanged(path, data, 0644)
 	if err != nil {
-		return fmt.Errorf("error creating markdown file: %w", err)
+		return err
+	}
+	o.Stats.Add(result)
+	return nil
+}
+
+// WriteBlocksToMarkdown writes a page to the markdown file at outputPath, along with any files its flavour needs next to it.
+func WriteBlocksToMarkdown(results *api.ResultsWrapper, outputPath string, page Page, linkTitles map[string]string, opts Options) error {
+	pageTitle := page.displayTitle()
+
+	// Nested layouts write pages into directories that may not exist yet
+	if opts.Sink == nil {
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return fmt.Errorf("error creating directory: %w", err)
+		}
+	}
+	if err := opts.Flavour.writeSectionFiles(outputPath, page, pageTitle, opts); err != nil {
+		return err
 	}
-	defer file.Close()
 
-	pageTitle := toTitleCase(pageName)
-	_, err = file.WriteString(fmt.Sprintf("# %s\n\n", pageTitle))
+	// The page is rendered in memory, and only replaces the previous version once it is complete
+	var buf bytes.Buffer
+	if err := RenderMarkdown(&buf, results, page, linkTitles, opts); err != nil {
+		return err
+	}
+	if err := opts.writeFile(outputPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("error writing markdown file: %w", err)
+	}
+
+	slog.Debug("wrote page", "title", pageTitle, "path", outputPath)
+	return nil
+}
+
+// RenderMarkdown writes a page's markdown to w. linkTitles holds the titles of the pages that link_to_page blocks point to.
+func RenderMarkdown(w io.Writer, results *api.ResultsWrapper, page Page, linkTitles map[string]string, opts Options) error {
+	pageTitle := page.displayTitle()
+	header := opts.Flavour.frontMatter(page, pageTitle)
+	if opts.Flavour.writesTitleHeading() {
+		header += fmt.Sprintf("# %s\n\n", pageTitle)
+	}
+	_, err := io.WriteString(w, header)
 	if err != nil {
 		return fmt.Errorf("error writing to markdown file: %w", err)
 	}
 
+	dialect := opts.dialect()
 	listItemNumber := 1
 	processingNumberedList := false
+	previousType := ""
 	for _, block := range results.Results {
 		var provider api.RichTextProvider
 		var markdownPrefix string
 		var formattedContent string
+		// continuation is the indent that keeps wrapped and broken lines within the block
+		continuation := ""
+		wrap := true
 
 		switch block.Type {
 		case "heading_1":
 			provider = block.Heading1
 			markdownPrefix = "# "
+			wrap = false
 			processingNumberedList = false
 		case "heading_2":
 			provider = block.Heading2
 			markdownPrefix = "## "
+			wrap = false
 			processingNumberedList = false
 		case "heading_3":
 			provider = block.Heading3
 			markdownPrefix = "### "
+			wrap = false
 			processingNumberedList = false
 		case "paragraph":
 			provider = block.Paragraph
@@ -86,42 +236,52 @@ func WriteBlocksToMarkdown(results *api.ResultsWrapper, outputPath string, pageN
 		case "quote":
 			provider = block.Quote
 			markdownPrefix = "> "
+			continuation = "> "
 			processingNumberedList = false
 		case "code":
-			provider = block.Code
-			markdownPrefix = "```" + block.Code.Language + "\n"
+			var code strings.Builder
+			for _, rt := range block.Code.RichText {
+				code.WriteString(rt.Text.Content)
+			}
+			formattedContent = protectCode(dialect.codeBlock(code.String(), block.Code.Language), opts)
 			processingNumberedList = false
 		case "divider":
-			markdownPrefix = "---"
+			formattedContent = "---"
 			processingNumberedList = false
 		case "child_page":
-			markdownPrefix = fmt.Sprintf("- [%s](%s.md)", block.ChildPage.Title, strcase.ToKebab(block.ChildPage.Title))
+			formattedContent = dialect.Bullet + " " + opts.Flavour.pageLink(block.ChildPage.Title, opts.childLinkDir(page))
 			processingNumberedList = false
 		case "link_to_page":
 			// Ensure to use block.LinkToPage.PageID as the key to fetch the title
 			pageID := block.LinkToPage.PageID
 			if title, ok := linkTitles[pageID]; ok {
-				markdownPrefix = fmt.Sprintf("- [%s](%s.md)\n", title, strcase.ToKebab(title))
-				_, err := file.WriteString(markdownPrefix)
-				if err != nil {
-					return fmt.Errorf("error writing link to markdown file: %w", err)
-				}
+				formattedContent = dialect.Bullet + " " + opts.Flavour.pageLink(title, page.RootPath)
 			}
 			processingNumberedList = false
 		case "bookmark":
-			markdownPrefix = "- [" + block.Bookmark.URL + "]"
+			formattedContent = dialect.Bullet + " [" + block.Bookmark.URL + "]"
+			processingNumberedList = false
+		case "callout":
+			provider = block.Callout
+			markdownPrefix = opts.Flavour.calloutPrefix(block.Callout)
+			continuation = "> "
+			processingNumberedList = false
+		case "image", "file", "pdf":
+			formattedContent = renderAsset(&block, opts)
 			processingNumberedList = false
 		case "to_do":
 			provider = block.Todo
 			if block.Todo.Checked {
-				markdownPrefix = "- [x] "
+				markdownPrefix = dialect.Bullet + " [x] "
 			} else {
-				markdownPrefix = "- [ ] "
+				markdownPrefix = dialect.Bullet + " [ ] "
 			}
+			continuation = "  "
 			processingNumberedList = false
 		case "bulleted_list_item":
 			provider = block.Bulleted
-			markdownPrefix = "- "
+			markdownPrefix = dialect.Bullet + " "
+			continuation = "  "
 			processingNumberedList = false
 		case "numbered_list_item":
 			if !processingNumberedList {
@@ -132,45 +292,35 @@ func WriteBlocksToMarkdown(results *api.ResultsWrapper, outputPath string, pageN
 			}
 			provider = block.Numbered
 			markdownPrefix = fmt.Sprintf("%d. ", listItemNumber)
+			continuation = strings.Repeat(" ", len(markdownPrefix))
 			// No reset here since we might be continuing the list
 		}
 
 		if provider != nil {
-			for _, rt := range provider.GetRichText() {
-				if block.Type == "code" {
-					formattedContent = markdownPrefix + rt.Text.Content + "\n ```\n"
-				} else {
-					formattedContent = markdownPrefix + applyAnnotationsToContent(rt) + "\n"
-				}
-				_, err := file.WriteString(formattedContent)
-				if err != nil {
-					return fmt.Errorf("error writing to markdown file: %w", err)
-				}
+			text := renderRichText(provider.GetRichText(), opts)
+			if !wrap {
+				// Headings cannot span lines
+				text = strings.ReplaceAll(text, "\n", " ")
+			}
+			if strings.TrimSpace(text) != "" {
+				formattedContent = dialect.formatLines(markdownPrefix, continuation, text, wrap)
 			}
 		}
 
-		if block.Divider != nil {
-			_, err := file.WriteString(fmt.Sprintf("%s\n", markdownPrefix))
-			if err != nil {
-				return fmt.Errorf("error writing to markdown file: %w", err)
-			}
+		// Blocks that are not supported yet are skipped
+		if formattedContent == "" {
+			continue
 		}
 
-		if block.Bookmark != nil {
-			_, err := file.WriteString(fmt.Sprintf("%s\n", markdownPrefix))
-			if err != nil {
-				return fmt.Errorf("error writing to markdown file: %w", err)
-			}
+		if dialect.BlankLines && previousType != "" && (listKind(block.Type) == "" || listKind(block.Type) != listKind(previousType)) {
+			formattedContent = "\n" + formattedContent
 		}
+		previousType = block.Type
 
-		if block.Type == "child_page" {
-			_, err := file.WriteString(fmt.Sprintf("%s\n", markdownPrefix))
-			if err != nil {
-				return fmt.Errorf("error writing to markdown file: %w", err)
-			}
+		_, err := io.WriteString(w, formattedContent+"\n")
+		if err != nil {
+			return fmt.Errorf("error writing to markdown file: %w", err)
 		}
 	}
-
-	fmt.Println(pageTitle, " has been written to a file.")
 	return nil
 }
@@ -2,11 +2,16 @@ package format
 
 import (
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
 )
 
 func TestToTitleCase(t *testing.T) {
@@ -103,7 +108,7 @@ func TestApplyAnnotationsToContent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := applyAnnotationsToContent(tt.rt)
+			result := applyAnnotationsToContent(tt.rt, Options{})
 			if result != tt.expected {
 				t.Errorf("applyAnnotationsToContent(%v) = %v, want %v", tt.rt, result, tt.expected)
 			}
@@ -213,7 +218,7 @@ func TestWriteBlocksToMarkdown(t *testing.T) {
 	outputPath := "./test_output.md"
 	pageName := "Test Page"
 	pageTitles := map[string]string{}
-	if err := WriteBlocksToMarkdown(results, outputPath, pageName, pageTitles); err != nil {
+	if err := WriteBlocksToMarkdown(results, outputPath, Page{Name: pageName}, pageTitles, Options{}); err != nil {
 		t.Errorf("WriteBlocksToMarkdown returned an error: %v", err)
 	}
 
@@ -240,7 +245,7 @@ func TestWriteBlocksToMarkdown(t *testing.T) {
 		"2. List Item Three\n",
 		"- [x] To Do Item\n",
 		"- [ ] To Do Item unchecked\n",
-		"```go\nCode Block\n ```\n",
+		"```go\nCode Block\n```\n",
 		"- [https://example.com]\n",
 		"> Quote Block\n",
 		"---\n",
@@ -255,3 +260,131 @@ func TestWriteBlocksToMarkdown(t *testing.T) {
 	// Clean up
 	os.Remove(outputPath)
 }
+
+// mapSink keeps the files it is given in memory.
+type mapSink map[string][]byte
+
+func (s mapSink) WriteFile(path string, data []byte) error {
+	s[path] = data
+	return nil
+}
+
+func TestWriteBlocksToMarkdownSink(t *testing.T) {
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{ID: "child", Type: "child_page", HasChildren: true, ChildPage: &api.ChildPage{Title: "Getting Started"}},
+		},
+	}
+
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "guide", "index.md")
+	sink := mapSink{}
+	opts := Options{Flavour: Docusaurus, Sink: sink}
+	if err := WriteBlocksToMarkdown(results, outputPath, Page{Name: "guide"}, map[string]string{}, opts); err != nil {
+		t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+	}
+
+	if !strings.Contains(string(sink[outputPath]), "Getting Started") {
+		t.Errorf("Expected the page in the sink, got %q", sink[outputPath])
+	}
+	if _, ok := sink[filepath.Join(dir, "guide", "_category_.json")]; !ok {
+		t.Errorf("Expected the category file in the sink, got %v", sink)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected nothing to be written to disk, found %d entries", len(entries))
+	}
+}
+
+func TestWriteBlocksToMarkdownSkipsUnchanged(t *testing.T) {
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{ID: "1", Type: "paragraph", Paragraph: &api.Paragraph{RichText: []api.RichText{{PlainText: "Hello", Text: api.Text{Content: "Hello"}}}}},
+		},
+	}
+	outputPath := filepath.Join(t.TempDir(), "page.md")
+	stats := &WriteStats{}
+	opts := Options{Stats: stats}
+
+	for i := 0; i < 2; i++ {
+		if err := WriteBlocksToMarkdown(results, outputPath, Page{Name: "page"}, map[string]string{}, opts); err != nil {
+			t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+		}
+	}
+	results.Results[0].Paragraph.RichText[0].Text.Content = "Goodbye"
+	if err := WriteBlocksToMarkdown(results, outputPath, Page{Name: "page"}, map[string]string{}, opts); err != nil {
+		t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+	}
+
+	counts := []int{stats.Count(atomicfile.Created), stats.Count(atomicfile.Updated), stats.Count(atomicfile.Unchanged)}
+	if !reflect.DeepEqual(counts, []int{1, 1, 1}) {
+		t.Errorf("Expected 1 created, 1 updated and 1 unchanged file, got %v", counts)
+	}
+}
+
+func TestAssetFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockType string
+		file      api.File
+		expected  string
+	}{
+		{"name from the URL", "image", api.File{Type: "file", File: &api.HostedFile{URL: "https://files.example.com/a/diagram.jpg?X-Amz-Signature=abc"}}, "1a2b3c4d-diagram.jpg"},
+		{"name from Notion", "file", api.File{Type: "external", External: &api.ExternalFile{URL: "https://example.com/"}, Name: "report.xlsx"}, "1a2b3c4d-report.xlsx"},
+		{"image without a name", "image", api.File{Type: "external", External: &api.ExternalFile{URL: "https://example.com"}}, "1a2b3c4d-image.png"},
+		{"image without an extension", "image", api.File{Type: "external", External: &api.ExternalFile{URL: "https://example.com/photos/12345"}}, "1a2b3c4d-12345.png"},
+		{"pdf without an extension", "pdf", api.File{Type: "external", External: &api.ExternalFile{URL: "https://example.com/download"}}, "1a2b3c4d-download.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := &api.Block{ID: "1a2b3c4d-0000-4000-8000-000000000001", Type: tt.blockType}
+			if got := assetFileName(block, &tt.file); got != tt.expected {
+				t.Errorf("assetFileName() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderAssetOffline(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("new image"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		offline  bool
+		onDisk   bool
+		expected string
+		requests int
+	}{
+		{"offline with the asset on disk", true, true, "![[1a2b3c4d-diagram.png]]", 0},
+		{"offline without the asset", true, false, "![](" + server.URL + "/diagram.png)", 0},
+		{"online", false, false, "![[1a2b3c4d-diagram.png]]", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			dir := t.TempDir()
+			if tt.onDisk {
+				if err := os.WriteFile(filepath.Join(dir, "1a2b3c4d-diagram.png"), []byte("old image"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			block := &api.Block{ID: "1a2b3c4d-0000-4000-8000-000000000001", Type: "image", Image: &api.File{
+				Type: "external", External: &api.ExternalFile{URL: server.URL + "/diagram.png"},
+			}}
+			opts := Options{Flavour: Obsidian, AssetDir: dir, Offline: tt.offline, Stats: &WriteStats{}}
+
+			if got := renderAsset(block, opts); got != tt.expected {
+				t.Errorf("renderAsset() = %q, want %q", got, tt.expected)
+			}
+			if requests != tt.requests {
+				t.Errorf("Expected %d requests for the asset, got %d", tt.requests, requests)
+			}
+		})
+	}
+}
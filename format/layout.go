@@ -0,0 +1,61 @@
+package format
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Layout decides where child pages are written for the flavours that do not dictate it themselves.
+type Layout string
+
+const (
+	// Flat writes child pages next to their parent.
+	Flat Layout = "flat"
+	// Nested writes child pages into a directory named after their parent.
+	Nested Layout = "nested"
+)
+
+// ParseLayout returns the Layout with the given name. An empty name is the Flat layout.
+func ParseLayout(name string) (Layout, error) {
+	switch layout := Layout(strings.ToLower(name)); layout {
+	case "":
+		return Flat, nil
+	case Flat, Nested:
+		return layout, nil
+	}
+	return "", fmt.Errorf("unknown layout %q, expected flat or nested", name)
+}
+
+// nests reports whether child pages are written into a directory of their own. Hugo and
+// Docusaurus already nest pages with children into sections, and Jekyll keeps every post in _posts.
+func (opts Options) nests() bool {
+	return opts.Layout == Nested && (opts.Flavour == Markdown || opts.Flavour == Obsidian || opts.Flavour == "")
+}
+
+// ChildDir returns the directory that the child pages of the page written to outputPath go in.
+func (opts Options) ChildDir(outputPath string) string {
+	if opts.nests() {
+		return strings.TrimSuffix(outputPath, ".md")
+	}
+	return filepath.Dir(outputPath)
+}
+
+// childLinkDir is the directory of a child page, relative to its parent, for use in links.
+func (opts Options) childLinkDir(page Page) string {
+	if opts.nests() {
+		return page.Name
+	}
+	return ""
+}
+
+// RootPath returns the path from dir back up to root, such as ../.., for links from pages in dir
+// to pages in root. It is empty when dir is root or is not inside it.
+func RootPath(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	depth := len(strings.Split(filepath.ToSlash(rel), "/"))
+	return strings.TrimSuffix(strings.Repeat("../", depth), "/")
+}
@@ -0,0 +1,103 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  Layout
+		expectErr bool
+	}{
+		{"", Flat, false},
+		{"Nested", Nested, false},
+		{"tree", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			layout, err := ParseLayout(tt.input)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseLayout() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if layout != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, layout)
+			}
+		})
+	}
+}
+
+func TestChildDir(t *testing.T) {
+	outputPath := filepath.Join("out", "guide.md")
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"flat markdown", Options{Layout: Flat}, "out"},
+		{"nested markdown", Options{Layout: Nested}, filepath.Join("out", "guide")},
+		{"nested obsidian", Options{Flavour: Obsidian, Layout: Nested}, filepath.Join("out", "guide")},
+		{"jekyll stays flat", Options{Flavour: Jekyll, Layout: Nested}, "out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.ChildDir(outputPath); got != tt.expected {
+				t.Errorf("ChildDir() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRootPath(t *testing.T) {
+	root := filepath.Join("out", "docs")
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{root, ""},
+		{filepath.Join(root, "guide"), ".."},
+		{filepath.Join(root, "guide", "setup"), "../.."},
+		{"elsewhere", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			if got := RootPath(root, tt.dir); got != tt.expected {
+				t.Errorf("RootPath() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNestedLayoutLinks(t *testing.T) {
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{ID: "c1", Type: "child_page", ChildPage: &api.ChildPage{Title: "Setup"}},
+			{ID: "l1", Type: "link_to_page", LinkToPage: &api.LinkToPage{PageID: "linked"}},
+		},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "guide.md")
+	page := Page{Name: "guide", RootPath: ".."}
+	err := WriteBlocksToMarkdown(results, outputPath, page, map[string]string{"linked": "Glossary"}, Options{Layout: Nested})
+	if err != nil {
+		t.Fatalf("WriteBlocksToMarkdown returned an error: %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	for _, link := range []string{"[Setup](guide/setup.md)", "[Glossary](../glossary.md)"} {
+		if !strings.Contains(string(content), link) {
+			t.Errorf("Expected the page to contain %s, got %q", link, content)
+		}
+	}
+}
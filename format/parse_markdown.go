@@ -0,0 +1,330 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+// MaxTextLength is the most characters Notion accepts in a single rich text object.
+const MaxTextLength = 2000
+
+var (
+	numberedItemPattern = regexp.MustCompile(`^\d+[.)]\s+`)
+	todoPattern         = regexp.MustCompile(`^[-*+]\s+\[([ xX])\]\s+`)
+	bulletPattern       = regexp.MustCompile(`^[-*+]\s+`)
+	dividerPattern      = regexp.MustCompile(`^(\*\s*){3,}$|^(-\s*){3,}$|^(_\s*){3,}$`)
+	imagePattern        = regexp.MustCompile(`^!\[([^\]]*)\]\((https?://[^)\s]+)\)$`)
+)
+
+// codeLanguages maps the short names used in code fences to the languages Notion knows.
+var codeLanguages = map[string]string{
+	"":       "plain text",
+	"text":   "plain text",
+	"txt":    "plain text",
+	"sh":     "shell",
+	"zsh":    "shell",
+	"js":     "javascript",
+	"ts":     "typescript",
+	"py":     "python",
+	"rb":     "ruby",
+	"golang": "go",
+	"yml":    "yaml",
+	"md":     "markdown",
+	"cpp":    "c++",
+	"cs":     "c#",
+}
+
+// ParseMarkdown turns a markdown document into Notion blocks, so that it can be pushed to a page.
+// It understands headings, paragraphs, lists, to-dos, quotes, code fences, dividers and images
+// with a web URL, along with bold, italic, strikethrough, code and links within text. Nested
+// list items are flattened, and anything else is kept as a paragraph of text.
+//
+// Front matter and a level 1 heading at the top are left out, since they hold the page's
+// properties and title, which are not part of its content.
+func ParseMarkdown(markdown string) []api.Block {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	lines = skipFrontMatter(lines)
+
+	var blocks []api.Block
+	var paragraph, quote []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, api.Block{Type: "paragraph", Paragraph: &api.Paragraph{RichText: parseInline(joinLines(paragraph))}})
+			paragraph = nil
+		}
+		if len(quote) > 0 {
+			blocks = append(blocks, api.Block{Type: "quote", Quote: &api.Quote{RichText: parseInline(joinLines(quote))}})
+			quote = nil
+		}
+	}
+
+	titleSkipped := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		if !titleSkipped {
+			titleSkipped = true
+			if strings.HasPrefix(trimmed, "# ") {
+				continue
+			}
+		}
+
+		if fence := codeFence(trimmed); fence != "" {
+			flush()
+			language := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(trimmed, fence)))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, codeBlock(strings.Join(code, "\n"), language))
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			if len(paragraph) > 0 {
+				flush()
+			}
+			quote = append(quote, strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			continue
+		}
+
+		// An indented line continues the list item above it
+		if indented := line != strings.TrimLeft(line, " \t"); indented && len(paragraph) == 0 && len(blocks) > 0 && !isListLine(trimmed) {
+			if text := listItemText(&blocks[len(blocks)-1]); text != nil {
+				*text = append(*text, parseInline(" "+trimmed)...)
+				continue
+			}
+		}
+
+		block, ok := lineBlock(trimmed)
+		if !ok {
+			if len(quote) > 0 {
+				flush()
+			}
+			paragraph = append(paragraph, trimmed)
+			continue
+		}
+		flush()
+		blocks = append(blocks, block)
+	}
+	flush()
+	return blocks
+}
+
+// skipFrontMatter drops the front matter at the start of a document.
+func skipFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// lineBlock returns the block for a line that stands on its own, like a heading or a list item.
+func lineBlock(line string) (api.Block, bool) {
+	switch {
+	case strings.HasPrefix(line, "### "), strings.HasPrefix(line, "#### "), strings.HasPrefix(line, "##### "), strings.HasPrefix(line, "###### "):
+		// Notion has three levels of heading
+		text := strings.TrimLeft(line, "#")
+		return api.Block{Type: "heading_3", Heading3: &api.Heading{RichText: parseInline(strings.TrimSpace(text))}}, true
+	case strings.HasPrefix(line, "## "):
+		return api.Block{Type: "heading_2", Heading2: &api.Heading{RichText: parseInline(line[3:])}}, true
+	case strings.HasPrefix(line, "# "):
+		return api.Block{Type: "heading_1", Heading1: &api.Heading{RichText: parseInline(line[2:])}}, true
+	case dividerPattern.MatchString(line):
+		return api.Block{Type: "divider", Divider: &api.Divider{}}, true
+	}
+
+	if matches := todoPattern.FindStringSubmatch(line); matches != nil {
+		text := parseInline(line[len(matches[0]):])
+		return api.Block{Type: "to_do", Todo: &api.Todo{RichText: text, Checked: matches[1] != " "}}, true
+	}
+	if match := bulletPattern.FindString(line); match != "" {
+		return api.Block{Type: "bulleted_list_item", Bulleted: &api.ListItem{RichText: parseInline(line[len(match):])}}, true
+	}
+	if match := numberedItemPattern.FindString(line); match != "" {
+		return api.Block{Type: "numbered_list_item", Numbered: &api.ListItem{RichText: parseInline(line[len(match):])}}, true
+	}
+	if matches := imagePattern.FindStringSubmatch(line); matches != nil {
+		image := &api.File{Type: "external", External: &api.ExternalFile{URL: matches[2]}, Caption: splitText(matches[1], api.Annotations{}, nil)}
+		return api.Block{Type: "image", Image: image}, true
+	}
+	return api.Block{}, false
+}
+
+func isListLine(line string) bool {
+	return bulletPattern.MatchString(line) || numberedItemPattern.MatchString(line)
+}
+
+// listItemText returns the rich text of a list item or to-do, or nil for any other block.
+func listItemText(block *api.Block) *[]api.RichText {
+	switch block.Type {
+	case "bulleted_list_item":
+		return &block.Bulleted.RichText
+	case "numbered_list_item":
+		return &block.Numbered.RichText
+	case "to_do":
+		return &block.Todo.RichText
+	}
+	return nil
+}
+
+// codeFence returns the fence a line opens a code block with, or an empty string.
+func codeFence(line string) string {
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, fence) {
+			return fence
+		}
+	}
+	return ""
+}
+
+func codeBlock(code, language string) api.Block {
+	if notionLanguage, ok := codeLanguages[language]; ok {
+		language = notionLanguage
+	}
+	text := splitText(code, api.Annotations{}, nil)
+	return api.Block{Type: "code", Code: &api.Code{RichText: text, Language: language}}
+}
+
+// joinLines joins the lines of a paragraph. A line ending in a backslash or two spaces is a hard line break.
+func joinLines(lines []string) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		if strings.HasSuffix(line, "\\") && i < len(lines)-1 {
+			sb.WriteString(strings.TrimSuffix(line, "\\"))
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return strings.ReplaceAll(sb.String(), "\n ", "\n")
+}
+
+// parseInline turns text with inline formatting into rich text. A marker only starts
+// formatting when it is closed later in the text, so a lone * is kept as it is.
+func parseInline(text string) []api.RichText {
+	var richText []api.RichText
+	var sb strings.Builder
+	var annotations api.Annotations
+	flush := func() {
+		if sb.Len() > 0 {
+			richText = append(richText, splitText(sb.String(), annotations, nil)...)
+			sb.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+		switch {
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			sb.WriteByte(text[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				flush()
+				code := annotations
+				code.Code = true
+				richText = append(richText, splitText(text[i+1:i+1+end], code, nil)...)
+				i += end + 2
+				continue
+			}
+		case c == '[':
+			if label, url, n, ok := parseLink(rest); ok {
+				flush()
+				for _, rt := range parseInline(label) {
+					rt.Annotations.Bold = rt.Annotations.Bold || annotations.Bold
+					rt.Annotations.Italic = rt.Annotations.Italic || annotations.Italic
+					rt.Annotations.Strikethrough = rt.Annotations.Strikethrough || annotations.Strikethrough
+					rt.Text.Link = &api.LinkObject{URL: &url}
+					richText = append(richText, rt)
+				}
+				i += n
+				continue
+			}
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if annotations.Bold || strings.Contains(text[i+2:], rest[:2]) {
+				flush()
+				annotations.Bold = !annotations.Bold
+				i += 2
+				continue
+			}
+		case strings.HasPrefix(rest, "~~"):
+			if annotations.Strikethrough || strings.Contains(text[i+2:], "~~") {
+				flush()
+				annotations.Strikethrough = !annotations.Strikethrough
+				i += 2
+				continue
+			}
+		case c == '*' || c == '_' && !isIntraword(text, i):
+			if annotations.Italic || strings.IndexByte(text[i+1:], c) >= 0 {
+				flush()
+				annotations.Italic = !annotations.Italic
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	flush()
+	return richText
+}
+
+// parseLink parses a [label](url) link at the start of text, returning the number of bytes it takes up.
+func parseLink(text string) (label, url string, n int, ok bool) {
+	closing := strings.Index(text, "](")
+	if closing < 0 {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(text[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	url = text[closing+2 : closing+2+end]
+	if url == "" || strings.ContainsAny(url, " \t") {
+		return "", "", 0, false
+	}
+	return text[1:closing], url, closing + 3 + end, true
+}
+
+// splitText creates text rich text objects, splitting the content so that none is longer than MaxTextLength.
+func splitText(content string, annotations api.Annotations, link *api.LinkObject) []api.RichText {
+	annotations.Color = "default"
+	var richText []api.RichText
+	for content != "" {
+		chunk := content
+		if utf8.RuneCountInString(chunk) > MaxTextLength {
+			chunk = string([]rune(chunk)[:MaxTextLength])
+		}
+		content = content[len(chunk):]
+		richText = append(richText, api.RichText{
+			Type:        "text",
+			Text:        api.Text{Content: chunk, Link: link},
+			Annotations: annotations,
+			PlainText:   chunk,
+		})
+	}
+	return richText
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && (unicode.IsPunct(rune(c)) || unicode.IsSymbol(rune(c)))
+}
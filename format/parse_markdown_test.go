@@ -0,0 +1,159 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestParseMarkdownBlocks(t *testing.T) {
+	markdown := strings.Join([]string{
+		"---",
+		"title: \"Notes\"",
+		"---",
+		"# Notes",
+		"",
+		"First line",
+		"continues here.",
+		"",
+		"## Section",
+		"- one",
+		"  wrapped",
+		"* two",
+		"1. first",
+		"- [x] done",
+		"- [ ] open",
+		"> quoted",
+		"> text",
+		"---",
+		"```js",
+		"let a = 1",
+		"",
+		"```",
+		"#### Deep",
+		"![A cat](https://example.com/cat.png)",
+	}, "\n")
+
+	blocks := ParseMarkdown(markdown)
+
+	var types []string
+	for _, block := range blocks {
+		types = append(types, block.Type)
+	}
+	expected := []string{
+		"paragraph", "heading_2", "bulleted_list_item", "bulleted_list_item", "numbered_list_item",
+		"to_do", "to_do", "quote", "divider", "code", "heading_3", "image",
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected blocks %v, got %v", expected, types)
+	}
+
+	if got := plainText(blocks[0].Paragraph.RichText); got != "First line continues here." {
+		t.Errorf("Expected the paragraph lines to be joined, got %q", got)
+	}
+	if got := plainText(blocks[2].Bulleted.RichText); got != "one wrapped" {
+		t.Errorf("Expected the indented line to continue the list item, got %q", got)
+	}
+	if !blocks[5].Todo.Checked || blocks[6].Todo.Checked {
+		t.Errorf("Expected only the first to-do to be checked")
+	}
+	if got := plainText(blocks[7].Quote.RichText); got != "quoted text" {
+		t.Errorf("Expected the quote lines to be joined, got %q", got)
+	}
+	if blocks[9].Code.Language != "javascript" || plainText(blocks[9].Code.RichText) != "let a = 1\n" {
+		t.Errorf("Unexpected code block %+v", blocks[9].Code)
+	}
+	if blocks[11].Image.URL() != "https://example.com/cat.png" || plainText(blocks[11].Image.Caption) != "A cat" {
+		t.Errorf("Unexpected image block %+v", blocks[11].Image)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	url := "https://example.com"
+	text := func(content string, annotations api.Annotations) api.RichText {
+		annotations.Color = "default"
+		return api.RichText{Type: "text", Text: api.Text{Content: content}, Annotations: annotations, PlainText: content}
+	}
+	link := func(rt api.RichText) api.RichText {
+		rt.Text.Link = &api.LinkObject{URL: &url}
+		return rt
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []api.RichText
+	}{
+		{
+			name:     "plain text",
+			input:    "Hello World",
+			expected: []api.RichText{text("Hello World", api.Annotations{})},
+		},
+		{
+			name:     "bold and italic",
+			input:    "a **bold** and *italic* word",
+			expected: []api.RichText{text("a ", api.Annotations{}), text("bold", api.Annotations{Bold: true}), text(" and ", api.Annotations{}), text("italic", api.Annotations{Italic: true}), text(" word", api.Annotations{})},
+		},
+		{
+			name:     "nested emphasis",
+			input:    "**bold *both***",
+			expected: []api.RichText{text("bold ", api.Annotations{Bold: true}), text("both", api.Annotations{Bold: true, Italic: true})},
+		},
+		{
+			name:     "code is not parsed",
+			input:    "run `a *b*`",
+			expected: []api.RichText{text("run ", api.Annotations{}), text("a *b*", api.Annotations{Code: true})},
+		},
+		{
+			name:     "link",
+			input:    "see [the **site**](https://example.com)",
+			expected: []api.RichText{text("see ", api.Annotations{}), link(text("the ", api.Annotations{})), link(text("site", api.Annotations{Bold: true}))},
+		},
+		{
+			name:     "escaped and unmatched markers are literal",
+			input:    `2 \* 3 * 4 snake_case ~~gone~~`,
+			expected: []api.RichText{text("2 * 3 * 4 snake_case ", api.Annotations{}), text("gone", api.Annotations{Strikethrough: true})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInline(tt.input); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseInline(%q) = %+v, want %+v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseInlineSplitsLongText(t *testing.T) {
+	long := strings.Repeat("é", MaxTextLength+10)
+	richText := parseInline(long)
+	if len(richText) != 2 {
+		t.Fatalf("Expected the text to be split in two, got %d parts", len(richText))
+	}
+	if n := len([]rune(richText[0].Text.Content)); n != MaxTextLength {
+		t.Errorf("Expected the first part to be %d characters, got %d", MaxTextLength, n)
+	}
+	if plainText(richText) != long {
+		t.Errorf("Expected no text to be lost")
+	}
+}
+
+func TestParseMarkdownRoundTrip(t *testing.T) {
+	richText := []api.RichText{
+		{Type: "text", Text: api.Text{Content: "Use "}, PlainText: "Use "},
+		{Type: "text", Text: api.Text{Content: "go test"}, Annotations: api.Annotations{Code: true}, PlainText: "go test"},
+		{Type: "text", Text: api.Text{Content: " for [all] *packages*"}, PlainText: " for [all] *packages*"},
+	}
+	markdown := "# Title\n\n" + renderRichText(richText, Options{}) + "\n"
+
+	blocks := ParseMarkdown(markdown)
+	if len(blocks) != 1 || blocks[0].Type != "paragraph" {
+		t.Fatalf("Expected a single paragraph, got %+v", blocks)
+	}
+	if got := plainText(blocks[0].Paragraph.RichText); got != "Use go test for [all] *packages*" {
+		t.Errorf("Expected the escaped text to be read back, got %q", got)
+	}
+}
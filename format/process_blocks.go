@@ -1,82 +1,93 @@
 package format
 
 import (
-	"fmt"
+	"io"
+	"log/slog"
 
-	"github.com/iancoleman/strcase"
 	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/progress"
 )
 
-func ProcessBlocks(uuid string, results *api.ResultsWrapper, outputPath, pageName string, apiClient api.NotionAPI, bearerToken string, processedBlocks map[string]string, outputDir string) {
+// PageRef is a page found while processing a page's blocks, that can be exported next.
+type PageRef struct {
+	ID    string
+	Title string
+	// Position is the 1-based position of a child page among its parent's child pages.
+	Position int
+	// Linked is set for pages reached through a link_to_page block rather than as a child page.
+	Linked bool
+}
+
+// ProcessBlocks writes a page to outputPath and returns the child and linked pages it refers to.
+// The pages it refers to are not processed here, it is up to the caller to export them.
+func ProcessBlocks(results *api.ResultsWrapper, outputPath string, page Page, apiClient api.NotionAPI, bearerToken string, opts Options) ([]PageRef, error) {
+	pages, linkTitles := findPages(results, apiClient, bearerToken)
+	if err := WriteBlocksToMarkdown(results, outputPath, page, linkTitles, opts); err != nil {
+		return nil, err
+	}
+	opts.Events.Emit(progress.Event{Kind: progress.Written, PageID: page.ID})
+	return pages, nil
+}
+
+// RenderPage writes a page to w instead of a file, without following the pages it refers to.
+func RenderPage(w io.Writer, results *api.ResultsWrapper, page Page, apiClient api.NotionAPI, bearerToken string, opts Options) error {
+	_, linkTitles := findPages(results, apiClient, bearerToken)
+	return RenderMarkdown(w, results, page, linkTitles, opts)
+}
+
+// findPages returns the child and linked pages among the blocks, along with the titles of the linked pages.
+func findPages(results *api.ResultsWrapper, apiClient api.NotionAPI, bearerToken string) ([]PageRef, map[string]string) {
 	linkTitles := make(map[string]string)
+	var pages []PageRef
+	position := 0
 
 	for _, block := range results.Results {
-		if _, processed := processedBlocks[block.ID]; processed {
-			continue
-		}
-
 		switch block.Type {
 		case "child_page":
-			processChildPageBlock(&block, apiClient, bearerToken, processedBlocks, linkTitles, outputDir)
+			position++
+			pages = append(pages, PageRef{ID: block.ID, Title: block.ChildPage.Title, Position: position})
 		case "link_to_page":
-			processLinkToPageBlock(&block, apiClient, bearerToken, processedBlocks, linkTitles, outputDir)
+			if ref, ok := processLinkToPageBlock(&block, apiClient, bearerToken, linkTitles); ok {
+				pages = append(pages, ref)
+			}
 		}
-
-		processedBlocks[block.ID] = outputPath
 	}
-
-	WriteBlocksToMarkdown(results, outputPath, pageName, linkTitles)
+	return pages, linkTitles
 }
 
-func processLinkToPageBlock(block *api.Block, apiClient api.NotionAPI, bearerToken string, processedBlocks, linkTitles map[string]string, outputDir string) {
-	title, err := api.FetchBlockTitle(apiClient, block.LinkToPage.PageID, bearerToken)
-	if err != nil {
-		fmt.Println("Error fetching title:", err)
-		return
+// PageMetadata describes the page being written, fetching its properties only when the flavour writes front matter.
+func PageMetadata(pageID, pageName string, apiClient api.NotionAPI, bearerToken string, opts Options) Page {
+	page := Page{ID: pageID, Name: pageName}
+	if !opts.Flavour.hasFrontMatter() {
+		return page
 	}
 
-	linkTitles[block.LinkToPage.PageID] = title
-	if _, processed := processedBlocks[block.LinkToPage.PageID]; !processed {
-		linkedResults, err := api.FetchChildBlocks(apiClient, block.LinkToPage.PageID, bearerToken)
-		if err != nil {
-			fmt.Println("Error fetching child blocks:", err)
-			return
-		}
-		linkedPageName := strcase.ToKebab(title)
-		linkedOutputPath := fmt.Sprintf("%s/%s.md", outputDir, linkedPageName)
-		processedBlocks[block.LinkToPage.PageID] = linkedOutputPath
-		WriteBlocksToMarkdown(linkedResults, linkedOutputPath, linkedPageName, linkTitles)
+	notionPage, err := api.FetchPage(apiClient, pageID, bearerToken)
+	if err != nil {
+		slog.Warn("error fetching page properties", "page_id", pageID, "err", err)
+		return page
 	}
+	return NewPage(pageName, notionPage)
 }
 
-func processChildPageBlock(block *api.Block, apiClient api.NotionAPI, bearerToken string, processedBlocks, linkTitles map[string]string, outputDir string) {
-	if !block.HasChildren {
-		return
+// HasChildPages reports whether any of the blocks is a child page.
+func HasChildPages(results *api.ResultsWrapper) bool {
+	for _, block := range results.Results {
+		if block.Type == "child_page" {
+			return true
+		}
 	}
-	processChildBlocks(block, apiClient, bearerToken, processedBlocks, linkTitles, outputDir)
+	return false
 }
 
-func processChildBlocks(parentBlock *api.Block, apiClient api.NotionAPI, bearerToken string, processedBlocks map[string]string, linkTitles map[string]string, outputDir string) {
-	childResults, err := api.FetchChildBlocks(apiClient, parentBlock.ID, bearerToken)
+// processLinkToPageBlock looks up the title of a linked page, which is needed for the link text.
+func processLinkToPageBlock(block *api.Block, apiClient api.NotionAPI, bearerToken string, linkTitles map[string]string) (PageRef, bool) {
+	title, err := api.FetchBlockTitle(apiClient, block.LinkToPage.PageID, bearerToken)
 	if err != nil {
-		fmt.Println("Error calling API for child blocks:", err)
-		return
+		slog.Warn("error fetching title of linked page", "page_id", block.LinkToPage.PageID, "err", err)
+		return PageRef{}, false
 	}
-	childPageName := strcase.ToKebab(parentBlock.ChildPage.Title)
-	childOutputPath := fmt.Sprintf("%s/%s.md", outputDir, childPageName)
 
-	// Before processing child blocks further, mark this parent block as processed to avoid infinite recursion
-	processedBlocks[parentBlock.ID] = childOutputPath
-
-	if err := WriteBlocksToMarkdown(childResults, childOutputPath, childPageName, linkTitles); err != nil {
-		fmt.Println("Error writing blocks to Markdown:", err)
-		return
-	}
-
-	// If child blocks have their own children, recursively process them too
-	for _, childBlock := range childResults.Results {
-		if childBlock.HasChildren {
-			processChildBlocks(&childBlock, apiClient, bearerToken, processedBlocks, linkTitles, outputDir)
-		}
-	}
+	linkTitles[block.LinkToPage.PageID] = title
+	return PageRef{ID: block.LinkToPage.PageID, Title: title, Linked: true}, true
 }
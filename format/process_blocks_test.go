@@ -3,6 +3,8 @@ package format
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/s-kngstn/notionsync/api" // adjust the import path based on your project structure
@@ -15,6 +17,12 @@ type MockNotionAPI struct {
 	FetchBlockTitleError  error
 	ChildBlocksResponse   *api.ResultsWrapper
 	FetchChildBlocksError error
+	PageResponse          *api.Page
+	FetchPageError        error
+	BlockResponse         *api.Block
+	FetchBlockError       error
+	SearchResponse        *api.SearchResponse
+	SearchError           error
 }
 
 func (m *MockNotionAPI) GetNotionBlockTitle(pageID, bearerToken string) (string, error) {
@@ -27,6 +35,30 @@ func (m *MockNotionAPI) GetNotionChildBlocks(blockID, bearerToken string) (*api.
 	return m.ChildBlocksResponse, m.FetchChildBlocksError
 }
 
+func (m *MockNotionAPI) GetNotionPage(pageID, bearerToken string) (*api.Page, error) {
+	return m.PageResponse, m.FetchPageError
+}
+
+func (m *MockNotionAPI) GetNotionBlock(blockID, bearerToken string) (*api.Block, error) {
+	return m.BlockResponse, m.FetchBlockError
+}
+
+func (m *MockNotionAPI) Search(request api.SearchRequest, bearerToken string) (*api.SearchResponse, error) {
+	return m.SearchResponse, m.SearchError
+}
+
+func (m *MockNotionAPI) GetBotUser(bearerToken string) (*api.User, error) {
+	return &api.User{Object: "user", Type: "bot"}, nil
+}
+
+func (m *MockNotionAPI) AppendBlockChildren(blockID string, children []api.Block, bearerToken string) error {
+	return nil
+}
+
+func (m *MockNotionAPI) DeleteBlock(blockID, bearerToken string) error {
+	return nil
+}
+
 func TestProcessBlocksMarkdownOutput(t *testing.T) {
 	// Setup Mock API with a response
 	mockAPI := &MockNotionAPI{
@@ -44,18 +76,17 @@ func TestProcessBlocksMarkdownOutput(t *testing.T) {
 	outputPath := tempFile.Name()
 	defer os.Remove(outputPath) // Clean up after the test
 
-	uuid := "test-uuid"
 	bearerToken := "test-token"
 	pageName := "Test Page"
-	processedBlocks := make(map[string]string)
-	outputDir := "test-output-dir"
 	results := &api.ResultsWrapper{
 		Results: []api.Block{
 			// Define a simple block structure for testing
 		},
 	}
 
-	ProcessBlocks(uuid, results, outputPath, pageName, mockAPI, bearerToken, processedBlocks, outputDir)
+	if _, err := ProcessBlocks(results, outputPath, Page{Name: pageName}, mockAPI, bearerToken, Options{}); err != nil {
+		t.Fatalf("ProcessBlocks returned an error: %v", err)
+	}
 
 	// Read the content of the temporary file
 	content, err := os.ReadFile(outputPath)
@@ -85,18 +116,47 @@ func TestProcessBlocksWithFetchBlockTitleError(t *testing.T) {
 	outputPath := tempFile.Name()
 	defer os.Remove(outputPath) // Clean up after the test
 
-	uuid := "error-test-uuid"
 	bearerToken := "error-test-token"
 	pageName := "Error Test Page"
-	processedBlocks := make(map[string]string)
-	outputDir := "error-test-output-dir"
 	results := &api.ResultsWrapper{
 		Results: []api.Block{
 			{ID: "error1", Type: "link_to_page", LinkToPage: &api.LinkToPage{PageID: "errorPage"}},
 		},
 	}
 
-	ProcessBlocks(uuid, results, outputPath, pageName, mockAPI, bearerToken, processedBlocks, outputDir)
+	pages, err := ProcessBlocks(results, outputPath, Page{Name: pageName}, mockAPI, bearerToken, Options{})
+	if err != nil {
+		t.Fatalf("ProcessBlocks returned an error: %v", err)
+	}
+
+	// A link whose title cannot be fetched is not followed
+	if len(pages) != 0 {
+		t.Errorf("Expected no pages to follow, got %+v", pages)
+	}
+}
+
+func TestProcessBlocksReturnsReferencedPages(t *testing.T) {
+	mockAPI := &MockNotionAPI{BlockTitleResponse: "Linked Page"}
+	results := &api.ResultsWrapper{
+		Results: []api.Block{
+			{ID: "c1", Type: "child_page", ChildPage: &api.ChildPage{Title: "First"}},
+			{ID: "l1", Type: "link_to_page", LinkToPage: &api.LinkToPage{PageID: "linked"}},
+			{ID: "c2", Type: "child_page", ChildPage: &api.ChildPage{Title: "Second"}},
+		},
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "page.md")
+	pages, err := ProcessBlocks(results, outputPath, Page{Name: "page"}, mockAPI, "test-token", Options{})
+	if err != nil {
+		t.Fatalf("ProcessBlocks returned an error: %v", err)
+	}
 
-	// Error handling test logic remains the same
+	expected := []PageRef{
+		{ID: "c1", Title: "First", Position: 1},
+		{ID: "linked", Title: "Linked Page", Linked: true},
+		{ID: "c2", Title: "Second", Position: 2},
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("Expected pages %+v, got %+v", expected, pages)
+	}
 }
@@ -0,0 +1,176 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+// marker is an inline span of formatting, such as emphasis or a link, that is opened and closed around text.
+type marker struct {
+	open, close string
+}
+
+// segment is a run of rich text that shares the same annotations and link.
+type segment struct {
+	rt      api.RichText
+	content string
+	link    string
+}
+
+// renderRichText renders a block's rich text array as a single inline string. Adjacent segments with the
+// same formatting are merged, and formatting shared by neighbouring segments is kept open across them, so
+// "**a *b***" is written rather than "**a*****b***".
+func renderRichText(richText []api.RichText, opts Options) string {
+	var sb strings.Builder
+	var open []marker
+	// Whitespace is held back until the next markers have been closed or opened,
+	// as markers next to whitespace are not read as formatting
+	pendingSpace := ""
+
+	for _, seg := range mergeSegments(richText, opts) {
+		leading, core, trailing := splitSurroundingSpace(seg.content)
+		if core == "" {
+			pendingSpace += seg.content
+			continue
+		}
+
+		markers := segmentMarkers(seg, opts)
+		// Keep the markers that are already open and still apply, close the rest in reverse order
+		keep := 0
+		for keep < len(open) && containsMarker(markers, open[keep]) {
+			keep++
+		}
+		lastClose := ""
+		for i := len(open) - 1; i >= keep; i-- {
+			sb.WriteString(open[i].close)
+			lastClose = open[i].close
+		}
+		open = open[:keep]
+
+		between := pendingSpace + leading
+		sb.WriteString(between)
+		for _, m := range markers {
+			if !containsMarker(open, m) {
+				// A marker opened right where another was closed would join its run of the same character,
+				// as in *a***b**, so it is written with the other one, as in *a*__b__
+				if between == "" && lastClose != "" && lastClose[len(lastClose)-1] == m.open[0] {
+					m = m.alternative()
+				}
+				sb.WriteString(m.open)
+				open = append(open, m)
+				lastClose = ""
+			}
+		}
+
+		sb.WriteString(renderCore(seg, core, opts))
+		pendingSpace = trailing
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString(open[i].close)
+	}
+	sb.WriteString(pendingSpace)
+	return sb.String()
+}
+
+// mergeSegments joins adjacent text segments that have the same annotations and link.
+// Colours are not written to markdown so they do not keep segments apart.
+func mergeSegments(richText []api.RichText, opts Options) []segment {
+	var segments []segment
+	for _, rt := range richText {
+		seg := segment{rt: rt, content: rt.Text.Content, link: segmentLink(rt, opts)}
+		if rt.Type == "mention" {
+			seg.content = rt.PlainText
+		}
+
+		if n := len(segments); n > 0 && canMerge(segments[n-1], seg) {
+			segments[n-1].content += seg.content
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+func canMerge(a, b segment) bool {
+	annotationsA, annotationsB := a.rt.Annotations, b.rt.Annotations
+	annotationsA.Color, annotationsB.Color = "", ""
+	return a.rt.Type != "mention" && b.rt.Type != "mention" && annotationsA == annotationsB && a.link == b.link
+}
+
+// segmentLink returns the URL a segment links to. Page mentions link back to Notion, except in
+// Obsidian where they become wikilinks instead.
+func segmentLink(rt api.RichText, opts Options) string {
+	if rt.Text.Link != nil && rt.Text.Link.URL != nil {
+		return *rt.Text.Link.URL
+	}
+	if isPageMention(rt) && opts.Flavour != Obsidian && rt.Href != nil {
+		return *rt.Href
+	}
+	return ""
+}
+
+func isPageMention(rt api.RichText) bool {
+	return rt.Mention != nil && rt.Mention.Page != nil
+}
+
+// segmentMarkers lists the markers that apply to a segment, outermost first.
+func segmentMarkers(seg segment, opts Options) []marker {
+	dialect := opts.dialect()
+	annotations := seg.rt.Annotations
+
+	var markers []marker
+	if seg.link != "" {
+		markers = append(markers, marker{"[", "](" + escapeURL(seg.link) + ")"})
+	}
+	// Markdown has no syntax for underline, dialects that allow inline HTML use <u>
+	if annotations.Underline && dialect.Underline {
+		markers = append(markers, marker{"<u>", "</u>"})
+	}
+	if annotations.Strikethrough {
+		if dialect.Strikethrough == "" {
+			markers = append(markers, marker{"<del>", "</del>"})
+		} else {
+			markers = append(markers, marker{dialect.Strikethrough, dialect.Strikethrough})
+		}
+	}
+	if annotations.Bold {
+		markers = append(markers, marker{dialect.Strong, dialect.Strong})
+	}
+	if annotations.Italic {
+		markers = append(markers, marker{dialect.Emphasis, dialect.Emphasis})
+	}
+	return markers
+}
+
+// alternative returns an emphasis marker written with the other of * and _, which markdown reads the same.
+// Other markers have no alternative and are returned as they are.
+func (m marker) alternative() marker {
+	if m.open != m.close || strings.Trim(m.open, "*_") != "" {
+		return m
+	}
+	swapped := strings.NewReplacer("*", "_", "_", "*").Replace(m.open)
+	return marker{swapped, swapped}
+}
+
+func containsMarker(markers []marker, m marker) bool {
+	for _, candidate := range markers {
+		if candidate == m || candidate == m.alternative() {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCore renders the text of a segment, without its markers or surrounding whitespace.
+func renderCore(seg segment, core string, opts Options) string {
+	switch {
+	case isPageMention(seg.rt) && opts.Flavour == Obsidian:
+		return opts.Flavour.pageLink(core, "")
+	case seg.rt.Annotations.Code:
+		// Code spans are innermost, as markdown inside them is not formatted
+		return protectCode(codeSpan(core), opts)
+	}
+	return escapeText(core, opts)
+}
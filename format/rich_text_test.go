@@ -0,0 +1,71 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestRenderRichText(t *testing.T) {
+	text := func(content string, annotations api.Annotations) api.RichText {
+		return api.RichText{Type: "text", Text: api.Text{Content: content}, Annotations: annotations}
+	}
+	bold := api.Annotations{Bold: true}
+	italic := api.Annotations{Italic: true}
+	boldItalic := api.Annotations{Bold: true, Italic: true}
+
+	tests := []struct {
+		name     string
+		richText []api.RichText
+		expected string
+	}{
+		{
+			name:     "plain segments are joined",
+			richText: []api.RichText{text("Hello ", api.Annotations{}), text("World", api.Annotations{})},
+			expected: "Hello World",
+		},
+		{
+			name:     "bold word in the middle",
+			richText: []api.RichText{text("a ", api.Annotations{}), text("bold", bold), text(" word", api.Annotations{})},
+			expected: "a **bold** word",
+		},
+		{
+			name:     "identical annotations are merged",
+			richText: []api.RichText{text("one ", bold), text("two", bold)},
+			expected: "**one two**",
+		},
+		{
+			name:     "shared annotations stay open",
+			richText: []api.RichText{text("bold ", bold), text("both", boldItalic), text(" italic", italic)},
+			expected: "**bold *both*** *italic*",
+		},
+		{
+			name:     "italic then bold",
+			richText: []api.RichText{text("a", italic), text("b", bold)},
+			expected: "*a*__b__",
+		},
+		{
+			name:     "bold then italic",
+			richText: []api.RichText{text("a", bold), text("b", italic), text(" c", bold)},
+			expected: "**a**_b_ **c**",
+		},
+		{
+			name:     "adjacent code spans are kept apart",
+			richText: []api.RichText{text("a", api.Annotations{Code: true}), text("b", api.Annotations{Code: true, Bold: true})},
+			expected: "`a`**`b`**",
+		},
+		{
+			name:     "whitespace only",
+			richText: []api.RichText{text(" ", bold)},
+			expected: " ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderRichText(tt.richText, Options{}); got != tt.expected {
+				t.Errorf("renderRichText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -5,5 +5,10 @@ go 1.21.4
 require (
 	github.com/iancoleman/strcase v0.3.0
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.16.0
+	golang.org/x/term v0.15.0
 	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.15.0 // indirect
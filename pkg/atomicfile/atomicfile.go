@@ -0,0 +1,117 @@
+package atomicfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File is written under a temporary name in the directory it belongs in, and only replaces the
+// file at its path when committed. Anyone reading the path sees the old content or the new, never
+// part of it, and a run that fails or is killed halfway leaves the old file as it was. Errors are
+// the ones from the os package, which name the file, for callers to wrap.
+type File struct {
+	*os.File
+	path string
+	done bool
+}
+
+// Create starts writing the file at path, which gets perm once committed.
+func Create(path string, perm os.FileMode) (*File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &File{File: tmp, path: path}, nil
+}
+
+// Commit flushes the file to disk and moves it into place.
+func (f *File) Commit() error {
+	if f.done {
+		return fmt.Errorf("%s was already committed or aborted", f.path)
+	}
+	f.done = true
+	if err := f.File.Sync(); err != nil {
+		f.File.Close()
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.path); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort throws the file away, leaving the file at its path untouched. It does nothing once the
+// file has been committed, so it can be deferred straight after Create.
+func (f *File) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// WriteFile replaces the file at path with data, as os.WriteFile does, but all at once.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := Create(path, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Result is what WriteIfChanged did to a file.
+type Result int
+
+const (
+	Created Result = iota
+	Updated
+	Unchanged
+)
+
+func (r Result) String() string {
+	switch r {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// WriteIfChanged is WriteFile, except that a file that already holds data is left alone, so that
+// its modification time stays the same and nothing watching it sees a change.
+func WriteIfChanged(path string, data []byte, perm os.FileMode) (Result, error) {
+	existing, err := os.ReadFile(path)
+	result := Updated
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		result = Created
+	case err != nil:
+		return 0, err
+	case bytes.Equal(existing, data):
+		return Unchanged, nil
+	}
+	if err := WriteFile(path, data, perm); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
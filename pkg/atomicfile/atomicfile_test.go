@@ -0,0 +1,127 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+	os.WriteFile(path, []byte("old\n"), 0644)
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	defer f.Abort()
+	f.WriteString("new\n")
+
+	// Until the file is committed the old content stays in place
+	if data, _ := os.ReadFile(path); string(data) != "old\n" {
+		t.Errorf("Expected the old content before commit, got %q", data)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("Expected the new content after commit, got %q", data)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected permissions 0644, got %v", info.Mode().Perm())
+	}
+	assertOnlyFile(t, dir, "page.md")
+}
+
+func TestAbortKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+	os.WriteFile(path, []byte("old\n"), 0644)
+
+	f, err := Create(path, 0644)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	f.WriteString("half a pa")
+	f.Abort()
+
+	if data, _ := os.ReadFile(path); string(data) != "old\n" {
+		t.Errorf("Expected the old content after abort, got %q", data)
+	}
+	assertOnlyFile(t, dir, "page.md")
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.json")
+	if err := WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %v", info.Mode().Perm())
+	}
+	assertOnlyFile(t, dir, "secret.json")
+}
+
+// assertOnlyFile checks that no temporary files were left behind.
+func assertOnlyFile(t *testing.T, dir, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only %s in the directory, got %v", name, names)
+	}
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+
+	tests := []struct {
+		name     string
+		data     string
+		expected Result
+	}{
+		{"new file", "one\n", Created},
+		{"same content", "one\n", Unchanged},
+		{"new content", "two\n", Updated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := os.Stat(path)
+			result, err := WriteIfChanged(path, []byte(tt.data), 0644)
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.data {
+				t.Errorf("Expected content %q, got %q", tt.data, data)
+			}
+			// An unchanged file is not written at all, so it is the same file as before
+			after, _ := os.Stat(path)
+			if tt.expected == Unchanged && !os.SameFile(before, after) {
+				t.Errorf("Expected the unchanged file not to be replaced")
+			}
+		})
+	}
+	assertOnlyFile(t, dir, "page.md")
+}
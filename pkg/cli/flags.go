@@ -0,0 +1,15 @@
+package cli
+
+import "strings"
+
+// StringList is a flag that can be given more than once, collecting every value.
+type StringList []string
+
+func (s *StringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *StringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
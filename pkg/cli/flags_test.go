@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestStringList(t *testing.T) {
+	var patterns StringList
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&patterns, "include", "")
+
+	if err := flags.Parse([]string{"-include", "Guides*", "-include=Team, Notes"}); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	expected := StringList{"Guides*", "Team, Notes"}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("Expected %v, got %v", expected, patterns)
+	}
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/s-kngstn/notionsync/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file looked for in the working directory.
+const FileName = "notionsync.yaml"
+
+// DefaultTokenEnv is the environment variable the token is read from when a profile does not say otherwise.
+const DefaultTokenEnv = "NOTION_API_KEY"
+
+// File is a config file, holding named sync profiles.
+//
+//	profiles:
+//	  docs:
+//	    token_env: NOTION_DOCS_KEY
+//	    output: site
+//	    format: hugo
+//	    max_depth: 2
+//	    targets:
+//	      - https://www.notion.so/Handbook-0123456789abcdef0123456789abcdef
+//	      - url: https://www.notion.so/Releases-fedcba9876543210fedcba9876543210
+//	        dir: releases
+type File struct {
+	// Path is the file the config was read from.
+	Path     string             `yaml:"-"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a predefined sync job. Fields left empty fall back to the command line defaults.
+// Relative paths are relative to the directory of the config file.
+type Profile struct {
+	// Token is the API token itself. Prefer TokenEnv or TokenFile so the token stays out of the config file.
+	Token string `yaml:"token"`
+	// TokenEnv is the environment variable holding the API token, NOTION_API_KEY by default.
+	TokenEnv string `yaml:"token_env"`
+	// TokenFile is a file holding the API token.
+	TokenFile string `yaml:"token_file"`
+	// Login is the name of a workspace whose token was saved with `notionsync auth login`.
+	Login string `yaml:"login"`
+
+	Output      string `yaml:"output"`
+	Format      string `yaml:"format"`
+	Dialect     string `yaml:"dialect"`
+	Layout      string `yaml:"layout"`
+	Concurrency int    `yaml:"concurrency"`
+
+	// File is a URL list file, read in addition to Targets.
+	File       string   `yaml:"file"`
+	Targets    []Target `yaml:"targets"`
+	Workspace  bool     `yaml:"workspace"`
+	Search     string   `yaml:"search"`
+	SearchType string   `yaml:"search_type"`
+
+	MaxDepth    *int     `yaml:"max_depth"`
+	FollowLinks string   `yaml:"follow_links"`
+	MaxPages    int      `yaml:"max_pages"`
+	Include     []string `yaml:"include"`
+	Exclude     []string `yaml:"exclude"`
+
+	configPath string
+}
+
+// Target is a page to sync. It is written either as a URL or ID on its own, or as a mapping
+// with a url and the same options as an entry in a URL list file.
+type Target struct {
+	URL    string `yaml:"url"`
+	Name   string `yaml:"name"`
+	Dir    string `yaml:"dir"`
+	Depth  *int   `yaml:"depth"`
+	Format string `yaml:"format"`
+
+	line int
+}
+
+// UnmarshalYAML accepts a bare URL as well as a mapping, and remembers the line for error messages.
+func (t *Target) UnmarshalYAML(node *yaml.Node) error {
+	t.line = node.Line
+	if node.Kind == yaml.ScalarNode {
+		t.URL = node.Value
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: a target should be a URL or a mapping with a url", node.Line)
+	}
+	// Decoding a node does not check for unknown fields, so check here
+	for i := 0; i < len(node.Content); i += 2 {
+		switch key := node.Content[i]; key.Value {
+		case "url", "name", "dir", "depth", "format":
+		default:
+			return fmt.Errorf("line %d: unknown target option %q, expected url, name, dir, depth or format", key.Line, key.Value)
+		}
+	}
+	type plain Target
+	return node.Decode((*plain)(t))
+}
+
+// Find returns the config file to use. An explicit path is used as it is. Otherwise FileName is looked
+// for in the working directory, then in notionsync/ in the user's config directory ($XDG_CONFIG_HOME
+// or ~/.config on Linux).
+func Find(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	candidates := []string{FileName}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(configDir, "notionsync", FileName))
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no config file found, looked for %s: %w", strings.Join(candidates, ", "), os.ErrNotExist)
+}
+
+// Load reads and checks a config file. Unknown keys are reported as errors, so that typos do not go unnoticed.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{Path: path}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name, profile := range file.Profiles {
+		profile.configPath = path
+		file.Profiles[name] = profile
+	}
+	return file, nil
+}
+
+// Profile returns the profile with the given name.
+func (f *File) Profile(name string) (Profile, error) {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%s: no profile named %q, expected one of: %s", f.Path, name, strings.Join(f.Names(), ", "))
+	}
+	return profile, nil
+}
+
+// Names returns the names of the profiles, sorted.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveToken reads the API token from the profile's token source. It returns an empty token,
+// not an error, when the source is not set, leaving it to the caller to ask for one. A Login
+// is not resolved here, as saved tokens are looked up by the caller.
+func (p Profile) ResolveToken() (string, error) {
+	switch {
+	case p.Token != "":
+		return p.Token, nil
+	case p.TokenFile != "":
+		data, err := os.ReadFile(p.path(p.TokenFile))
+		if err != nil {
+			return "", fmt.Errorf("error reading token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case p.TokenEnv != "":
+		return os.Getenv(p.TokenEnv), nil
+	}
+	return os.Getenv(DefaultTokenEnv), nil
+}
+
+// OutputDir returns the directory to sync into, or an empty string when it is not set.
+func (p Profile) OutputDir() string {
+	if p.Output == "" {
+		return ""
+	}
+	return p.path(p.Output)
+}
+
+// ReadTargets returns the pages to sync, from both the URL list file and the targets in the config.
+// Every invalid target is reported, with the line it is on.
+func (p Profile) ReadTargets() ([]utils.Target, error) {
+	var targets []utils.Target
+	var errs []error
+	if p.File != "" {
+		fileTargets, err := utils.ReadTargets(p.path(p.File))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		targets = append(targets, fileTargets...)
+	}
+
+	for _, t := range p.Targets {
+		target, err := t.toTarget()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", p.configPath, t.line, err))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, errors.Join(errs...)
+}
+
+func (t Target) toTarget() (utils.Target, error) {
+	target, err := utils.NewTarget(t.URL)
+	if err != nil {
+		return utils.Target{}, err
+	}
+	target.Line = t.line
+
+	options := [][2]string{{"name", t.Name}, {"dir", t.Dir}, {"format", t.Format}}
+	if t.Depth != nil {
+		options = append(options, [2]string{"depth", strconv.Itoa(*t.Depth)})
+	}
+	for _, option := range options {
+		if option[1] == "" {
+			continue
+		}
+		if err := target.SetOption(option[0], option[1]); err != nil {
+			return utils.Target{}, err
+		}
+	}
+	return target, nil
+}
+
+// path resolves a path from the config file against the directory the file is in.
+func (p Profile) path(name string) string {
+	if filepath.IsAbs(name) || p.configPath == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(p.configPath), name)
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+)
+
+const sampleConfig = `profiles:
+  docs:
+    token_env: NOTION_DOCS_KEY
+    output: site
+    format: hugo
+    layout: nested
+    max_depth: 2
+    follow_links: same-tree
+    exclude: ["Drafts*"]
+    targets:
+      - https://www.notion.so/Handbook-0123456789abcdef0123456789abcdef
+      - url: fedcba9876543210fedcba9876543210
+        dir: releases
+        depth: 0
+  notes:
+    token_file: secrets/token
+    workspace: true
+`
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file, err := Load(writeConfig(t, dir, sampleConfig))
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	if names := file.Names(); len(names) != 2 || names[0] != "docs" || names[1] != "notes" {
+		t.Errorf("Expected profiles docs and notes, got %v", names)
+	}
+
+	docs, err := file.Profile("docs")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if docs.Format != "hugo" || docs.Layout != "nested" || docs.FollowLinks != "same-tree" || *docs.MaxDepth != 2 {
+		t.Errorf("Unexpected profile settings: %+v", docs)
+	}
+	if docs.OutputDir() != filepath.Join(dir, "site") {
+		t.Errorf("Expected the output directory to be relative to the config file, got %s", docs.OutputDir())
+	}
+
+	targets, err := docs.ReadTargets()
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].Ref.Name != "handbook" || targets[0].Line != 11 {
+		t.Errorf("Unexpected first target: %+v", targets[0])
+	}
+	if targets[1].Ref.Type != fetch.PageRef || targets[1].Dir != "releases" || *targets[1].Depth != 0 || targets[1].Flavour != "" {
+		t.Errorf("Unexpected second target: %+v", targets[1])
+	}
+
+	if _, err := file.Profile("blog"); err == nil || !strings.Contains(err.Error(), "docs, notes") {
+		t.Errorf("Expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"unknown key", "profiles:\n  docs:\n    formt: hugo\n", "line 3: field formt not found"},
+		{"unknown target option", "profiles:\n  docs:\n    targets:\n      - url: 0123456789abcdef0123456789abcdef\n        colour: red\n", `line 5: unknown target option "colour"`},
+		{"not yaml", "profiles: [", "yaml:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, t.TempDir(), tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestReadTargetsErrors(t *testing.T) {
+	content := `profiles:
+  docs:
+    targets:
+      - notes about the handbook
+      - url: 0123456789abcdef0123456789abcdef
+        format: pdf
+`
+	path := writeConfig(t, t.TempDir(), content)
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	_, err = file.Profiles["docs"].ReadTargets()
+	if err == nil {
+		t.Fatalf("Expected an error but did not get one")
+	}
+	for _, message := range []string{path + `:4: "notes about the handbook" is not a Notion URL`, path + `:5: unknown format "pdf"`} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected the error to contain %q, got %q", message, err)
+		}
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "secrets"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets", "token"), []byte("secret_from_file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	file, err := Load(writeConfig(t, dir, sampleConfig))
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	t.Setenv("NOTION_DOCS_KEY", "secret_from_env")
+	t.Setenv(DefaultTokenEnv, "secret_default")
+
+	tests := []struct {
+		name     string
+		profile  Profile
+		expected string
+	}{
+		{"token env", file.Profiles["docs"], "secret_from_env"},
+		{"token file", file.Profiles["notes"], "secret_from_file"},
+		{"literal token", Profile{Token: "secret_literal"}, "secret_literal"},
+		{"default env", Profile{}, "secret_default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.profile.ResolveToken()
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if token != tt.expected {
+				t.Errorf("Expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	if path, err := Find("custom.yaml"); err != nil || path != "custom.yaml" {
+		t.Errorf("Expected an explicit path to be used as it is, got %q, %v", path, err)
+	}
+
+	workDir := t.TempDir()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Skipf("No user config directory: %v", err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	if _, err := Find(""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+
+	userConfig := filepath.Join(userConfigDir, "notionsync")
+	if err := os.MkdirAll(userConfig, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	writeConfig(t, userConfig, sampleConfig)
+	if path, err := Find(""); err != nil || path != filepath.Join(userConfig, FileName) {
+		t.Errorf("Expected the user config file, got %q, %v", path, err)
+	}
+
+	writeConfig(t, workDir, sampleConfig)
+	if path, err := Find(""); err != nil || path != FileName {
+		t.Errorf("Expected the config file in the working directory, got %q, %v", path, err)
+	}
+}
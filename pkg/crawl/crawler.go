@@ -0,0 +1,313 @@
+package crawl
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/progress"
+)
+
+// DefaultConcurrency is the number of pages exported at the same time when none is configured.
+const DefaultConcurrency = 4
+
+// Task is a page waiting to be exported.
+type Task struct {
+	PageID string
+	// Name is the file name the page is written to, without the .md extension.
+	Name string
+	// Dir is the directory the page is written into.
+	Dir string
+	// Position is the 1-based position of a child page among its parent's child pages.
+	Position int
+	// Depth is the number of child page and link hops from the page the crawl started at.
+	Depth int
+	// Settings replace the crawler's own for this page and every page crawled from it, when not nil.
+	Settings *Settings
+}
+
+// Settings are the options that can be set for each root page rather than for the whole crawl.
+type Settings struct {
+	Options format.Options
+	// LinkedDir is the directory that pages reached through links are written into.
+	LinkedDir string
+	// MaxDepth replaces Scope.MaxDepth.
+	MaxDepth int
+}
+
+// Crawler exports pages with a bounded pool of workers. Workers take pages from a queue, and the
+// child and linked pages of every page they export are added to the queue rather than recursed into.
+type Crawler struct {
+	API         api.NotionAPI
+	BearerToken string
+	Options     format.Options
+	// Concurrency is the number of workers, and so the most pages fetched at the same time.
+	Concurrency int
+	// LinkedDir is the directory that pages reached through links are written into.
+	LinkedDir string
+	// Registry holds the pages already seen. It is shared by every root, so a page is
+	// exported once per run however many roots or links lead to it.
+	Registry *Registry
+	// Report collects how the export of every page ended.
+	Report *Report
+	// Scope limits which child and linked pages are crawled.
+	Scope Scope
+	// StateDir is the directory the state of the crawl is saved into as it runs, when it is not
+	// empty, so that the crawl can be resumed with Restore if it is interrupted.
+	StateDir string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []Task
+	active  int
+	roots   map[string]bool
+	limited bool
+	// running and failed hold the pages being exported and the pages that failed, by compact ID.
+	running map[string]Task
+	failed  map[string]Task
+
+	restored      []Task
+	restoredRoots []string
+	startedAt     time.Time
+	lastSave      time.Time
+	saveMu        sync.Mutex
+	stateErrOnce  sync.Once
+}
+
+// NewCrawler creates a Crawler with the default concurrency, that crawls every page reachable from the roots.
+func NewCrawler(apiClient api.NotionAPI, bearerToken string, opts format.Options, linkedDir string) *Crawler {
+	return &Crawler{
+		API:         apiClient,
+		BearerToken: bearerToken,
+		Options:     opts,
+		Concurrency: DefaultConcurrency,
+		LinkedDir:   linkedDir,
+		Registry:    NewRegistry(),
+		Report:      NewReport(),
+		Scope:       DefaultScope(),
+	}
+}
+
+// Run exports the root pages and every page reachable from them, returning once the queue is empty.
+func (c *Crawler) Run(roots []Task) {
+	c.mu.Lock()
+	c.cond = sync.NewCond(&c.mu)
+	if c.Registry == nil {
+		c.Registry = NewRegistry()
+	}
+	if c.Report == nil {
+		c.Report = NewReport()
+	}
+	if c.startedAt.IsZero() {
+		c.startedAt = time.Now()
+	}
+	c.running = make(map[string]Task)
+	c.failed = make(map[string]Task)
+	c.roots = make(map[string]bool)
+	for _, id := range c.restoredRoots {
+		c.roots[api.NormalizeID(id)] = true
+	}
+	for _, root := range roots {
+		c.roots[api.NormalizeID(root.PageID)] = true
+	}
+	c.mu.Unlock()
+
+	// Pages restored from a saved state go first, as they were queued before the roots were
+	for _, task := range c.restored {
+		c.enqueue(task)
+	}
+	c.restored = nil
+	for _, root := range roots {
+		c.enqueue(root)
+	}
+
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task, ok := c.next()
+				if !ok {
+					return
+				}
+				c.process(task)
+				c.done(task)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.StateDir != "" {
+		c.saveState()
+	}
+}
+
+// StartedAt returns when the crawl started, or when the crawl it was restored from first started.
+func (c *Crawler) StartedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startedAt
+}
+
+// Failed returns the pages that could not be exported, ordered by name.
+func (c *Crawler) Failed() []Task {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failed := make([]Task, 0, len(c.failed))
+	for _, task := range c.failed {
+		failed = append(failed, task)
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		if failed[i].Name != failed[j].Name {
+			return failed[i].Name < failed[j].Name
+		}
+		return failed[i].PageID < failed[j].PageID
+	})
+	return failed
+}
+
+// enqueue adds a page to the queue, unless it has already been claimed in the registry
+// or the run has reached its page limit.
+func (c *Crawler) enqueue(task Task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Scope.MaxPages > 0 && c.Registry.Len() >= c.Scope.MaxPages {
+		if !c.limited {
+			slog.Warn("reached the page limit, skipping the rest", "max_pages", c.Scope.MaxPages)
+			c.limited = true
+		}
+		if !c.Registry.Claimed(task.PageID) {
+			c.Report.Add(PageResult{PageID: task.PageID, Name: task.Name, Status: Skipped, Reason: "page limit reached"})
+		}
+		return
+	}
+	if !c.Registry.Claim(task.PageID) {
+		return
+	}
+	c.events(task).Emit(progress.Event{Kind: progress.Discovered, PageID: task.PageID})
+	c.queue = append(c.queue, task)
+	c.cond.Signal()
+}
+
+// next takes the next page off the queue, waiting while other workers may still add to it.
+// It returns false once the queue is empty and no worker is busy.
+func (c *Crawler) next() (Task, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.queue) == 0 && c.active > 0 {
+		c.cond.Wait()
+	}
+	if len(c.queue) == 0 {
+		return Task{}, false
+	}
+	task := c.queue[0]
+	c.queue = c.queue[1:]
+	c.active++
+	c.running[api.NormalizeID(task.PageID)] = task
+	return task, true
+}
+
+// done marks a page taken with next as finished, and saves the state of the crawl if it is time to.
+func (c *Crawler) done(task Task) {
+	c.mu.Lock()
+	c.active--
+	delete(c.running, api.NormalizeID(task.PageID))
+	if c.active == 0 && len(c.queue) == 0 {
+		// Wake the idle workers so they can see that the crawl is over
+		c.cond.Broadcast()
+	}
+	save := c.StateDir != "" && time.Since(c.lastSave) >= stateSaveInterval
+	if save {
+		c.lastSave = time.Now()
+	}
+	c.mu.Unlock()
+
+	if save {
+		c.saveState()
+	}
+}
+
+// fail records a page that could not be exported.
+func (c *Crawler) fail(task Task, err *PageError) {
+	c.Report.Add(failedResult(task, err))
+	c.events(task).Emit(progress.Event{Kind: progress.Failed, PageID: task.PageID})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed[api.NormalizeID(task.PageID)] = task
+}
+
+// process fetches and writes a single page, and queues the pages it refers to.
+func (c *Crawler) process(task Task) {
+	events := c.events(task)
+	results, err := api.FetchChildBlocks(c.API, task.PageID, c.BearerToken)
+	if err != nil {
+		slog.Error("error fetching page", "page_id", task.PageID, "err", err)
+		c.fail(task, fetchError(task.PageID, err))
+		return
+	}
+	events.Emit(progress.Event{Kind: progress.Fetched, PageID: task.PageID})
+
+	opts, linkedDir, scope := c.Options, c.LinkedDir, c.Scope
+	if task.Settings != nil {
+		opts, linkedDir = task.Settings.Options, task.Settings.LinkedDir
+		scope.MaxDepth = task.Settings.MaxDepth
+	}
+
+	page := format.PageMetadata(task.PageID, task.Name, c.API, c.BearerToken, opts)
+	page.Position = task.Position
+	page.RootPath = format.RootPath(linkedDir, task.Dir)
+	outputPath := opts.Flavour.PagePath(task.Dir, page, format.HasChildPages(results))
+
+	pages, err := format.ProcessBlocks(results, outputPath, page, c.API, c.BearerToken, opts)
+	if err != nil {
+		slog.Error("error writing page", "page_id", task.PageID, "err", err)
+		c.fail(task, &PageError{PageID: task.PageID, Kind: WriteError, Err: err})
+		return
+	}
+	c.Registry.SetPath(task.PageID, outputPath)
+	c.Report.Add(PageResult{PageID: task.PageID, Name: task.Name, Status: Exported, Path: outputPath})
+
+	for _, ref := range pages {
+		name := strcase.ToKebab(ref.Title)
+		if reason := scope.skipReason(ref, task.Depth+1); reason != "" {
+			c.Report.Add(PageResult{PageID: ref.ID, Name: name, Status: Skipped, Reason: reason})
+			continue
+		}
+		if ref.Linked && scope.FollowLinks == FollowSameTree && !c.inTree(ref.ID) {
+			c.Report.Add(PageResult{PageID: ref.ID, Name: name, Status: Skipped, Reason: "not underneath a page being exported"})
+			continue
+		}
+		next := Task{
+			PageID:   ref.ID,
+			Name:     name,
+			Position: ref.Position,
+			Depth:    task.Depth + 1,
+			Settings: task.Settings,
+		}
+		if ref.Linked {
+			next.Dir = linkedDir
+		} else {
+			// Child pages are written next to or underneath their parent, depending on the flavour and layout
+			next.Dir = opts.ChildDir(outputPath)
+		}
+		c.enqueue(next)
+	}
+}
+
+// events is the listener for the events of a page, which ProcessBlocks also writes to.
+func (c *Crawler) events(task Task) progress.Listener {
+	if task.Settings != nil {
+		return task.Settings.Options.Events
+	}
+	return c.Options.Events
+}
@@ -0,0 +1,388 @@
+package crawl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/progress"
+)
+
+// mockNotionAPI serves pages from a map, and is safe to call from several workers at once.
+type mockNotionAPI struct {
+	children map[string][]api.Block
+	titles   map[string]string
+	pages    map[string]*api.Page
+	parents  map[string]string
+	delay    time.Duration
+
+	mu       sync.Mutex
+	fetches  map[string]int
+	inFlight int
+	maxSeen  int
+}
+
+func (m *mockNotionAPI) GetNotionBlockTitle(pageID, bearerToken string) (string, error) {
+	title, ok := m.titles[pageID]
+	if !ok {
+		return "", fmt.Errorf("page %s not found", pageID)
+	}
+	return title, nil
+}
+
+func (m *mockNotionAPI) GetNotionChildBlocks(blockID, bearerToken string) (*api.ResultsWrapper, error) {
+	m.mu.Lock()
+	if m.fetches == nil {
+		m.fetches = make(map[string]int)
+	}
+	m.fetches[blockID]++
+	m.inFlight++
+	m.maxSeen = max(m.maxSeen, m.inFlight)
+	m.mu.Unlock()
+
+	time.Sleep(m.delay)
+
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+
+	blocks, ok := m.children[blockID]
+	if !ok {
+		return nil, fmt.Errorf("page %s not found", blockID)
+	}
+	return &api.ResultsWrapper{Results: blocks}, nil
+}
+
+func (m *mockNotionAPI) GetNotionPage(pageID, bearerToken string) (*api.Page, error) {
+	page, ok := m.pages[pageID]
+	if !ok {
+		return nil, fmt.Errorf("page %s not found", pageID)
+	}
+	return page, nil
+}
+
+func (m *mockNotionAPI) GetNotionBlock(blockID, bearerToken string) (*api.Block, error) {
+	parent, ok := m.parents[blockID]
+	if !ok {
+		return &api.Block{ID: blockID, Parent: &api.Parent{Type: "workspace", Workspace: true}}, nil
+	}
+	return &api.Block{ID: blockID, Parent: &api.Parent{Type: "page_id", PageID: parent}}, nil
+}
+
+func (m *mockNotionAPI) Search(request api.SearchRequest, bearerToken string) (*api.SearchResponse, error) {
+	return &api.SearchResponse{}, nil
+}
+
+func (m *mockNotionAPI) GetBotUser(bearerToken string) (*api.User, error) {
+	return &api.User{Object: "user", Type: "bot"}, nil
+}
+
+func (m *mockNotionAPI) AppendBlockChildren(blockID string, children []api.Block, bearerToken string) error {
+	return fmt.Errorf("the crawler should not write to Notion")
+}
+
+func (m *mockNotionAPI) DeleteBlock(blockID, bearerToken string) error {
+	return fmt.Errorf("the crawler should not write to Notion")
+}
+
+func childPage(id, title string) api.Block {
+	return api.Block{ID: id, Type: "child_page", HasChildren: true, ChildPage: &api.ChildPage{Title: title}}
+}
+
+func linkToPage(id string) api.Block {
+	return api.Block{ID: "link-" + id, Type: "link_to_page", LinkToPage: &api.LinkToPage{PageID: id}}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestCrawlerWritesEveryPageOnce(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":   {childPage("child", "Child Page"), linkToPage("other")},
+			"child":  {linkToPage("root")},
+			"other":  {linkToPage("child")},
+			"second": {linkToPage("other")},
+		},
+		titles: map[string]string{"root": "Root", "child": "Child Page", "other": "Other Page"},
+	}
+
+	dir := t.TempDir()
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.Run([]Task{
+		{PageID: "root", Name: "root", Dir: dir},
+		{PageID: "second", Name: "second", Dir: dir},
+	})
+
+	for id, count := range mockAPI.fetches {
+		if count != 1 {
+			t.Errorf("Expected page %s to be fetched once, was fetched %d times", id, count)
+		}
+	}
+	if len(mockAPI.fetches) != 4 {
+		t.Errorf("Expected 4 pages to be fetched, got %d", len(mockAPI.fetches))
+	}
+
+	readFile(t, filepath.Join(dir, "root.md"))
+	readFile(t, filepath.Join(dir, "second.md"))
+	if content := readFile(t, filepath.Join(dir, "child-page.md")); !strings.HasPrefix(content, "# Child Page\n") {
+		t.Errorf("Unexpected child page content: %q", content)
+	}
+	if content := readFile(t, filepath.Join(dir, "other-page.md")); !strings.Contains(content, "[Child Page](child-page.md)") {
+		t.Errorf("Linked page does not link back to the child page: %q", content)
+	}
+}
+
+func TestCrawlerLimitsConcurrency(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{"root": {}},
+		delay:    5 * time.Millisecond,
+	}
+	var blocks []api.Block
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("page-%d", i)
+		blocks = append(blocks, childPage(id, fmt.Sprintf("Page %d", i)))
+		mockAPI.children[id] = nil
+	}
+	mockAPI.children["root"] = blocks
+
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, t.TempDir())
+	crawler.Concurrency = 3
+	crawler.Run([]Task{{PageID: "root", Name: "root", Dir: crawler.LinkedDir}})
+
+	if len(mockAPI.fetches) != 21 {
+		t.Errorf("Expected 21 pages to be fetched, got %d", len(mockAPI.fetches))
+	}
+	if mockAPI.maxSeen > 3 {
+		t.Errorf("Expected at most 3 pages to be fetched at once, saw %d", mockAPI.maxSeen)
+	}
+}
+
+func TestCrawlerNestsChildPages(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root": {childPage("child", "Getting Started")},
+			"child": {
+				{ID: "p1", Type: "paragraph", Paragraph: &api.Paragraph{RichText: []api.RichText{{Text: api.Text{Content: "Install it"}}}}},
+			},
+		},
+		pages: map[string]*api.Page{
+			"root":  {ID: "root"},
+			"child": {ID: "child", CreatedTime: "2024-03-05T10:00:00.000Z"},
+		},
+	}
+
+	contentDir := filepath.Join(t.TempDir(), format.Docusaurus.ContentDir())
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{Flavour: format.Docusaurus}, contentDir)
+	crawler.Run([]Task{{PageID: "root", Name: "guide", Dir: contentDir}})
+
+	readFile(t, filepath.Join(contentDir, "guide", "index.md"))
+	child := readFile(t, filepath.Join(contentDir, "guide", "getting-started.md"))
+	if !strings.HasPrefix(child, "---\ntitle: \"Getting Started\"\nslug: /getting-started\nsidebar_position: 1\n---\n\nInstall it\n") {
+		t.Errorf("Unexpected child page content: %q", child)
+	}
+}
+
+func TestCrawlerSharesPagesAcrossRoots(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{"shared": {childPage("nested", "Nested")}, "nested": nil},
+		titles:   map[string]string{"shared": "Shared"},
+	}
+	var roots []Task
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("root-%d", i)
+		mockAPI.children[id] = []api.Block{linkToPage("shared")}
+		roots = append(roots, Task{PageID: id, Name: id, Dir: dir})
+	}
+
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.Concurrency = 8
+	crawler.Run(roots)
+
+	for _, id := range []string{"shared", "nested"} {
+		if count := mockAPI.fetches[id]; count != 1 {
+			t.Errorf("Expected page %s to be fetched once, was fetched %d times", id, count)
+		}
+	}
+	if path, ok := crawler.Registry.Path("shared"); !ok || path != filepath.Join(dir, "shared.md") {
+		t.Errorf("Expected the shared page to be registered at shared.md, got %q", path)
+	}
+	if crawler.Registry.Len() != 12 {
+		t.Errorf("Expected 12 pages in the registry, got %d", crawler.Registry.Len())
+	}
+}
+
+func TestCrawlerScope(t *testing.T) {
+	// root has a child and links to a page elsewhere in its tree and to a page outside it
+	children := map[string][]api.Block{
+		"root":       {childPage("child", "Child"), linkToPage("cousin"), linkToPage("elsewhere")},
+		"child":      {childPage("grandchild", "Grandchild")},
+		"grandchild": nil,
+		"cousin":     nil,
+		"elsewhere":  nil,
+	}
+	titles := map[string]string{"cousin": "Cousin", "elsewhere": "Elsewhere"}
+	parents := map[string]string{"child": "root", "grandchild": "child", "cousin": "child"}
+
+	tests := []struct {
+		name     string
+		scope    Scope
+		expected []string
+	}{
+		{"everything", DefaultScope(), []string{"root", "child", "grandchild", "cousin", "elsewhere"}},
+		{"max depth", Scope{MaxDepth: 1, FollowLinks: FollowAll}, []string{"root", "child", "cousin", "elsewhere"}},
+		{"no links", Scope{MaxDepth: -1, FollowLinks: FollowNone}, []string{"root", "child", "grandchild"}},
+		{"same tree", Scope{MaxDepth: -1, FollowLinks: FollowSameTree}, []string{"root", "child", "grandchild", "cousin"}},
+		{"max pages", Scope{MaxDepth: -1, FollowLinks: FollowAll, MaxPages: 2}, []string{"root", "child"}},
+		{"exclude", Scope{MaxDepth: -1, FollowLinks: FollowAll, Exclude: []string{"child"}}, []string{"root", "cousin", "elsewhere"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAPI := &mockNotionAPI{children: children, titles: titles, parents: parents}
+			crawler := NewCrawler(mockAPI, "test-token", format.Options{}, t.TempDir())
+			crawler.Concurrency = 1
+			crawler.Scope = tt.scope
+			crawler.Run([]Task{{PageID: "root", Name: "root", Dir: crawler.LinkedDir}})
+
+			if len(mockAPI.fetches) != len(tt.expected) {
+				t.Errorf("Expected %d pages to be crawled, got %d: %v", len(tt.expected), len(mockAPI.fetches), mockAPI.fetches)
+			}
+			for _, id := range tt.expected {
+				if mockAPI.fetches[id] != 1 {
+					t.Errorf("Expected page %s to be crawled", id)
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlerRootSettings(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"shallow":       {childPage("shallow-child", "Shallow Child")},
+			"deep":          {childPage("deep-child", "Deep Child"), linkToPage("linked")},
+			"linked":        nil,
+			"deep-child":    nil,
+			"shallow-child": nil,
+		},
+		titles: map[string]string{"linked": "Linked"},
+		pages: map[string]*api.Page{
+			"deep":       {ID: "deep"},
+			"deep-child": {ID: "deep-child"},
+			"linked":     {ID: "linked"},
+		},
+	}
+
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.Run([]Task{
+		{PageID: "shallow", Name: "shallow", Dir: dir, Settings: &Settings{LinkedDir: dir, MaxDepth: 0}},
+		{PageID: "deep", Name: "deep", Dir: docs, Settings: &Settings{Options: format.Options{Flavour: format.Docusaurus}, LinkedDir: docs, MaxDepth: -1}},
+	})
+
+	if mockAPI.fetches["shallow-child"] != 0 {
+		t.Errorf("Expected the depth setting to stop the crawl at the root page")
+	}
+	readFile(t, filepath.Join(docs, "deep", "index.md"))
+	readFile(t, filepath.Join(docs, "deep", "deep-child.md"))
+	if content := readFile(t, filepath.Join(docs, "linked.md")); !strings.HasPrefix(content, "---\n") {
+		t.Errorf("Expected the linked page to be written with the root's format, got %q", content)
+	}
+}
+
+func TestCrawlerNestedLayout(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":   {childPage("child", "Child")},
+			"child":  {linkToPage("linked")},
+			"linked": nil,
+		},
+		titles: map[string]string{"linked": "Linked"},
+	}
+
+	dir := t.TempDir()
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{Layout: format.Nested}, dir)
+	crawler.Run([]Task{{PageID: "root", Name: "root", Dir: dir}})
+
+	child := readFile(t, filepath.Join(dir, "root", "child.md"))
+	if !strings.Contains(child, "[Linked](../linked.md)") {
+		t.Errorf("Expected the nested page to link back up to the linked page, got %q", child)
+	}
+	readFile(t, filepath.Join(dir, "linked.md"))
+}
+
+func TestCrawlerReport(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":  {childPage("child", "Child"), childPage("missing", "Missing"), childPage("draft", "Draft")},
+			"child": nil,
+		},
+	}
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, t.TempDir())
+	crawler.Scope.Exclude = []string{"Draft"}
+	crawler.Run([]Task{{PageID: "root", Name: "root", Dir: crawler.LinkedDir}})
+
+	statuses := make(map[string]Status)
+	for _, result := range crawler.Report.Results() {
+		statuses[result.PageID] = result.Status
+	}
+	expected := map[string]Status{"root": Exported, "child": Exported, "missing": Failed, "draft": Skipped}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+	if failed := crawler.Report.Results()[0]; failed.PageID != "missing" || failed.ErrorKind != RequestError {
+		t.Errorf("Expected the failed page first, with its error kind, got %+v", failed)
+	}
+}
+
+func TestCrawlerEvents(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":  {childPage("child", "Child Page"), childPage("gone", "Gone"), linkToPage("root")},
+			"child": {},
+		},
+		titles: map[string]string{"root": "Root"},
+	}
+
+	display := progress.NewDisplay(nil, false)
+	dir := t.TempDir()
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{Events: display.Handle}, dir)
+	crawler.Run([]Task{{PageID: "root", Name: "root", Dir: dir}})
+
+	// The page that cannot be fetched counts as failed, and the link back to the root is not discovered again
+	expected := progress.Counts{Discovered: 3, Fetched: 2, Written: 2, Failed: 1}
+	if counts := display.Counts(); counts != expected {
+		t.Errorf("Expected counts %+v, got %+v", expected, counts)
+	}
+}
+
+func TestCrawlerMatchesIDsInEitherForm(t *testing.T) {
+	// The root comes from a URL in compact form, and links to it come back from the API dashed
+	compact, dashed := "0123456789abcdef0123456789abcdef", "01234567-89ab-cdef-0123-456789abcdef"
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{compact: {childPage("child", "Child")}, "child": {linkToPage(dashed)}},
+	}
+	dir := t.TempDir()
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.Run([]Task{{PageID: compact, Name: "root", Dir: dir}, {PageID: dashed, Name: "root", Dir: dir}})
+
+	if len(mockAPI.fetches) != 2 || mockAPI.fetches[compact] != 1 {
+		t.Errorf("Expected the root to be fetched once whatever the form of its ID, got %v", mockAPI.fetches)
+	}
+}
@@ -0,0 +1,77 @@
+package crawl
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fakenotion"
+)
+
+// TestCrawlerAgainstFakeServer crawls a small workspace served over HTTP, through the same client
+// chain the commands use, with faults injected on one of its pages.
+func TestCrawlerAgainstFakeServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    *fakenotion.Fault
+		expected map[string]Status
+		kind     ErrorKind
+	}{
+		{"no faults", nil, map[string]Status{"Wiki": Exported, "Handbook": Exported, "Long read": Exported}, ""},
+		{"rate limited", &fakenotion.Fault{Status: http.StatusTooManyRequests, Times: 3}, map[string]Status{"Wiki": Exported, "Handbook": Exported, "Long read": Exported}, ""},
+		{"server error", &fakenotion.Fault{Status: http.StatusInternalServerError}, map[string]Status{"Wiki": Exported, "Handbook": Failed, "Long read": Exported}, APIError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakenotion.New("test-token")
+			defer server.Close()
+			wiki := server.AddPage("", "Wiki", api.Block{Type: "paragraph", Paragraph: &api.Paragraph{RichText: plainText("Welcome")}})
+			handbook := server.AddPage(wiki, "Handbook")
+			titles := map[string]string{wiki: "Wiki", handbook: "Handbook"}
+			var blocks []api.Block
+			for i := 0; i < 120; i++ {
+				blocks = append(blocks, api.Block{Type: "paragraph", Paragraph: &api.Paragraph{RichText: plainText(fmt.Sprint("Line ", i))}})
+			}
+			titles[server.AddPage(wiki, "Long read", blocks...)] = "Long read"
+			if tt.fault != nil {
+				fault := *tt.fault
+				if fault.Status != http.StatusTooManyRequests {
+					fault.Path = "/v1/blocks/" + handbook
+				}
+				server.Inject(fault)
+			}
+
+			limited := api.NewRateLimitedClient(server.Client(), api.DefaultRequestsPerSecond)
+			limited.Interval = 0
+			dir := t.TempDir()
+			crawler := NewCrawler(server.APIClientWith(limited), "test-token", format.Options{}, dir)
+			crawler.Run([]Task{{PageID: wiki, Name: "wiki", Dir: dir}})
+
+			statuses := make(map[string]Status)
+			for _, result := range crawler.Report.Results() {
+				statuses[titles[result.PageID]] = result.Status
+				if result.Status == Failed && result.ErrorKind != tt.kind {
+					t.Errorf("Expected %s to fail with %s, got %s: %s", result.Name, tt.kind, result.ErrorKind, result.Error)
+				}
+			}
+			for name, status := range tt.expected {
+				if statuses[name] != status {
+					t.Errorf("Expected %s to be %s, got %s", name, status, statuses[name])
+				}
+			}
+
+			if long := readFile(t, filepath.Join(dir, "long-read.md")); !strings.Contains(long, "Line 119") {
+				t.Errorf("Expected every page of the long page's blocks to be written, got:\n%s", long)
+			}
+		})
+	}
+}
+
+func plainText(text string) []api.RichText {
+	return []api.RichText{{Type: "text", Text: api.Text{Content: text}, PlainText: text}}
+}
@@ -0,0 +1,76 @@
+package crawl
+
+import (
+	"sync"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+// Registry records every page seen during a run, so that a page reachable from several
+// roots or links is only fetched and written once. Page IDs are compared in their compact
+// form, so an ID taken from a URL matches the same ID returned by the API. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.Mutex
+	pages map[string]string
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{pages: make(map[string]string)}
+}
+
+// Claim marks a page as seen, and reports whether this is the first time it has been claimed.
+// Only the caller that gets true should fetch and write the page.
+func (r *Registry) Claim(pageID string) bool {
+	pageID = api.NormalizeID(pageID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pages[pageID]; ok {
+		return false
+	}
+	r.pages[pageID] = ""
+	return true
+}
+
+// SetPath records the file a claimed page was written to.
+func (r *Registry) SetPath(pageID, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages[api.NormalizeID(pageID)] = path
+}
+
+// Claimed reports whether a page has been claimed, whether or not it was written.
+func (r *Registry) Claimed(pageID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.pages[api.NormalizeID(pageID)]
+	return ok
+}
+
+// Path returns the file a page was written to, or false if it has not been written.
+func (r *Registry) Path(pageID string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := r.pages[api.NormalizeID(pageID)]
+	return path, path != ""
+}
+
+// Written returns the file every written page was written to, keyed by the page's compact ID.
+func (r *Registry) Written() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	written := make(map[string]string)
+	for pageID, path := range r.pages {
+		if path != "" {
+			written[pageID] = path
+		}
+	}
+	return written
+}
+
+// Len returns the number of pages claimed.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pages)
+}
@@ -0,0 +1,78 @@
+package crawl
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryClaimsEachPageOnce(t *testing.T) {
+	registry := NewRegistry()
+
+	var mu sync.Mutex
+	claims := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("page-%d", j)
+				if registry.Claim(id) {
+					mu.Lock()
+					claims[id]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claims) != 50 || registry.Len() != 50 {
+		t.Fatalf("Expected 50 pages to be claimed, got %d", len(claims))
+	}
+	for id, count := range claims {
+		if count != 1 {
+			t.Errorf("Expected page %s to be claimed once, was claimed %d times", id, count)
+		}
+	}
+}
+
+func TestRegistryPath(t *testing.T) {
+	registry := NewRegistry()
+	registry.Claim("page")
+	if _, ok := registry.Path("page"); ok {
+		t.Errorf("Expected no path before the page is written")
+	}
+
+	registry.SetPath("page", "out/page.md")
+	if path, ok := registry.Path("page"); !ok || path != "out/page.md" {
+		t.Errorf("Expected path out/page.md, got %q", path)
+	}
+
+	registry.Claim("other")
+	if written := registry.Written(); len(written) != 1 || written["page"] != "out/page.md" {
+		t.Errorf("Expected only the written page, got %v", written)
+	}
+	if !registry.Claimed("other") || registry.Claimed("unseen") {
+		t.Errorf("Expected only claimed pages to be reported as claimed")
+	}
+}
+
+func TestRegistryComparesIDsInCompactForm(t *testing.T) {
+	registry := NewRegistry()
+	if !registry.Claim("0123456789abcdef0123456789ABCDEF") {
+		t.Fatalf("Expected the first claim to succeed")
+	}
+	if registry.Claim("01234567-89ab-cdef-0123-456789abcdef") {
+		t.Errorf("Expected the dashed form of a claimed ID to be claimed already")
+	}
+
+	registry.SetPath("01234567-89ab-cdef-0123-456789abcdef", "out/page.md")
+	if path, ok := registry.Path("0123456789abcdef0123456789abcdef"); !ok || path != "out/page.md" {
+		t.Errorf("Expected path out/page.md for the compact ID, got %q", path)
+	}
+	if registry.Len() != 1 {
+		t.Errorf("Expected 1 page in the registry, got %d", registry.Len())
+	}
+}
@@ -0,0 +1,114 @@
+package crawl
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fixture"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestReplay exports the pages in each testdata/replay/*/fixture.json through the real API client, with
+// the recorded responses standing in for Notion, and compares the files written with the expected directory
+// next to the fixture. Run `go test ./pkg/crawl -update` to regenerate them after an intended change in output.
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		rootID string
+		root   string
+		opts   format.Options
+	}{
+		{"wiki", "1a2b3c4d-0000-4000-8000-000000000001", "team-wiki", format.Options{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caseDir := filepath.Join("testdata", "replay", tt.name)
+			replayer, err := fixture.LoadReplayer(filepath.Join(caseDir, "fixture.json"))
+			if err != nil {
+				t.Fatalf("Failed to load fixture: %v", err)
+			}
+
+			dir := t.TempDir()
+			crawler := NewCrawler(api.NewNotionApiClient(replayer), "test-token", tt.opts, dir)
+			crawler.Run([]Task{{PageID: tt.rootID, Name: tt.root, Dir: dir}})
+
+			for _, result := range crawler.Report.Results() {
+				if result.Status == Failed {
+					t.Errorf("Page %s failed: %s", result.Name, result.Error)
+				}
+			}
+			for _, interaction := range replayer.Unused() {
+				t.Errorf("Expected a request for %s %s", interaction.Request.Method, interaction.Request.URL)
+			}
+
+			got := readTree(t, dir)
+			expectedDir := filepath.Join(caseDir, "expected")
+			if *update {
+				os.RemoveAll(expectedDir)
+				for name, content := range got {
+					path := filepath.Join(expectedDir, filepath.FromSlash(name))
+					os.MkdirAll(filepath.Dir(path), 0755)
+					if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+						t.Fatalf("Failed to update golden file: %v", err)
+					}
+				}
+			}
+			want := readTree(t, expectedDir)
+
+			for _, name := range sortedKeys(want) {
+				if content, ok := got[name]; !ok {
+					t.Errorf("Expected %s to be written", name)
+				} else if content != want[name] {
+					t.Errorf("Output does not match %s:\n%s\nwant:\n%s", filepath.Join(expectedDir, name), content, want[name])
+				}
+			}
+			for _, name := range sortedKeys(got) {
+				if _, ok := want[name]; !ok {
+					t.Errorf("Did not expect %s to be written", name)
+				}
+			}
+		})
+	}
+}
+
+// readTree reads every file under dir, keyed by its slash separated path relative to dir.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	return files
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
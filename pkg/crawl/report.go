@@ -0,0 +1,204 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+// Status is how the export of a page ended.
+type Status string
+
+const (
+	Exported Status = "ok"
+	Skipped  Status = "skipped"
+	Failed   Status = "failed"
+)
+
+// ErrorKind sorts the reasons a page failed to export.
+type ErrorKind string
+
+const (
+	NotFound     ErrorKind = "not_found"
+	Unauthorized ErrorKind = "unauthorized"
+	RateLimited  ErrorKind = "rate_limited"
+	APIError     ErrorKind = "api_error"
+	RequestError ErrorKind = "request_error"
+	WriteError   ErrorKind = "write_error"
+	// NotCached is a page missing from the cache of an offline run.
+	NotCached ErrorKind = "not_cached"
+)
+
+// PageError is why a page failed to export.
+type PageError struct {
+	PageID string
+	Kind   ErrorKind
+	Err    error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %s: %v", e.PageID, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// fetchError sorts an error from fetching a page by the response the API gave, if it gave one.
+func fetchError(pageID string, err error) *PageError {
+	kind := RequestError
+	var apiError *api.APIErrorResponse
+	if errors.Is(err, api.ErrNotCached) {
+		kind = NotCached
+	} else if errors.As(err, &apiError) {
+		switch apiError.Status {
+		case http.StatusNotFound:
+			kind = NotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			kind = Unauthorized
+		case http.StatusTooManyRequests:
+			kind = RateLimited
+		default:
+			kind = APIError
+		}
+	}
+	return &PageError{PageID: pageID, Kind: kind, Err: err}
+}
+
+// PageResult is how the export of a single page ended.
+type PageResult struct {
+	PageID string `json:"page_id"`
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// Path is the file an exported page was written to.
+	Path string `json:"path,omitempty"`
+	// Reason says why a page was skipped.
+	Reason    string    `json:"reason,omitempty"`
+	ErrorKind ErrorKind `json:"error_kind,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// failedResult is the result of a page that failed with err.
+func failedResult(task Task, err *PageError) PageResult {
+	return PageResult{PageID: task.PageID, Name: task.Name, Status: Failed, ErrorKind: err.Kind, Error: err.Err.Error()}
+}
+
+// Report collects how every page of a run ended. It is safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	results map[string]PageResult
+}
+
+// NewReport creates an empty Report.
+func NewReport() *Report {
+	return &Report{results: make(map[string]PageResult)}
+}
+
+// Add records the result of a page. Pages are compared by their compact ID, and a page that
+// was skipped along one path but reached along another is reported by how its export ended.
+func (r *Report) Add(result PageResult) {
+	id := api.NormalizeID(result.PageID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.results[id]; ok && result.Status == Skipped && existing.Status != Skipped {
+		return
+	}
+	r.results[id] = result
+}
+
+// SetPath changes the file a page was written to, for pages that are moved after they are exported.
+func (r *Report) SetPath(pageID, path string) {
+	id := api.NormalizeID(pageID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if result, ok := r.results[id]; ok {
+		result.Path = path
+		r.results[id] = result
+	}
+}
+
+// Result returns the result of a page, or false if nothing has been reported for it.
+func (r *Report) Result(pageID string) (PageResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.results[api.NormalizeID(pageID)]
+	return result, ok
+}
+
+// Results returns the result of every page: failures first, then skipped pages, then the pages
+// exported, each ordered by name.
+func (r *Report) Results() []PageResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]PageResult, 0, len(r.results))
+	for _, result := range r.results {
+		results = append(results, result)
+	}
+	order := map[Status]int{Failed: 0, Skipped: 1, Exported: 2}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Status != results[j].Status {
+			return order[results[i].Status] < order[results[j].Status]
+		}
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].PageID < results[j].PageID
+	})
+	return results
+}
+
+// Count returns the number of pages whose export ended with status.
+func (r *Report) Count(status Status) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, result := range r.results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Print writes a table of the pages that failed or were skipped, and the number of pages with each status.
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, result := range r.Results() {
+		switch result.Status {
+		case Failed:
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s: %s\n", result.Status, result.Name, result.PageID, result.ErrorKind, result.Error)
+		case Skipped:
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Status, result.Name, result.PageID, result.Reason)
+		}
+	}
+	fmt.Fprintf(tw, "%d pages exported, %d skipped, %d failed\n", r.Count(Exported), r.Count(Skipped), r.Count(Failed))
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes the result of every page as JSON, along with the number of pages with each status.
+func (r *Report) WriteJSON(w io.Writer) error {
+	report := struct {
+		Exported int          `json:"exported"`
+		Skipped  int          `json:"skipped"`
+		Failed   int          `json:"failed"`
+		Pages    []PageResult `json:"pages"`
+	}{r.Count(Exported), r.Count(Skipped), r.Count(Failed), r.Results()}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
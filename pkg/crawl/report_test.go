@@ -0,0 +1,81 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+func TestFetchErrorKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{"not found", &api.APIErrorResponse{Status: 404, Code: "object_not_found"}, NotFound},
+		{"unauthorized", &api.APIErrorResponse{Status: 401, Code: "unauthorized"}, Unauthorized},
+		{"restricted", &api.APIErrorResponse{Status: 403, Code: "restricted_resource"}, Unauthorized},
+		{"rate limited", fmt.Errorf("wrapped: %w", &api.APIErrorResponse{Status: 429, Code: "rate_limited"}), RateLimited},
+		{"server error", &api.APIErrorResponse{Status: 502}, APIError},
+		{"network", errors.New("connection reset"), RequestError},
+		{"offline", fmt.Errorf("block page: %w", api.ErrNotCached), NotCached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fetchError("page", tt.err)
+			if err.Kind != tt.expected {
+				t.Errorf("Expected kind %s, got %s", tt.expected, err.Kind)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Expected the page error to wrap the original error")
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+	report.Add(PageResult{PageID: "aaaa", Name: "skipped-then-exported", Status: Skipped, Reason: "excluded"})
+	report.Add(PageResult{PageID: "aaaa", Name: "skipped-then-exported", Status: Exported, Path: "out/a.md"})
+	report.Add(PageResult{PageID: "bbbb", Name: "exported-then-skipped", Status: Exported, Path: "out/b.md"})
+	report.Add(PageResult{PageID: "bbbb", Name: "exported-then-skipped", Status: Skipped, Reason: "excluded"})
+	report.Add(PageResult{PageID: "cccc", Name: "draft", Status: Skipped, Reason: "excluded"})
+	report.Add(PageResult{PageID: "dddd", Name: "gone", Status: Failed, ErrorKind: NotFound, Error: "API Error: object_not_found - Could not find page."})
+
+	if report.Count(Exported) != 2 || report.Count(Skipped) != 1 || report.Count(Failed) != 1 {
+		t.Errorf("Unexpected counts: %d exported, %d skipped, %d failed", report.Count(Exported), report.Count(Skipped), report.Count(Failed))
+	}
+
+	var out bytes.Buffer
+	if err := report.Print(&out); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "failed") || !strings.HasPrefix(lines[1], "skipped") {
+		t.Fatalf("Expected the failed page, the skipped page and a summary, got:\n%s", out.String())
+	}
+	if !strings.Contains(lines[0], "not_found: API Error") || lines[2] != "2 pages exported, 1 skipped, 1 failed" {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	var decoded struct {
+		Failed int          `json:"failed"`
+		Pages  []PageResult `json:"pages"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to parse the JSON report: %v", err)
+	}
+	if decoded.Failed != 1 || len(decoded.Pages) != 4 || decoded.Pages[0].ErrorKind != NotFound {
+		t.Errorf("Unexpected JSON report: %s", out.String())
+	}
+}
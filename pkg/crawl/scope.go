@@ -0,0 +1,110 @@
+package crawl
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+)
+
+// LinkPolicy says which pages reached through link_to_page blocks are crawled.
+type LinkPolicy string
+
+const (
+	// FollowNone crawls child pages only.
+	FollowNone LinkPolicy = "none"
+	// FollowSameTree crawls linked pages that are descendants of one of the root pages.
+	FollowSameTree LinkPolicy = "same-tree"
+	// FollowAll crawls every linked page.
+	FollowAll LinkPolicy = "all"
+)
+
+// maxAncestors bounds the walk up a linked page's parents, in case of a cycle in what the API returns.
+const maxAncestors = 50
+
+// ParseLinkPolicy parses the value of the -follow-links flag. An empty value follows every link.
+func ParseLinkPolicy(s string) (LinkPolicy, error) {
+	switch policy := LinkPolicy(strings.ToLower(s)); policy {
+	case "":
+		return FollowAll, nil
+	case FollowNone, FollowSameTree, FollowAll:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown link policy %q, expected none, same-tree or all", s)
+}
+
+// Scope limits which pages are crawled beyond the root pages, which are always exported.
+// Pages outside the scope are still linked to from the pages that refer to them, but are
+// not fetched, and neither are the pages underneath them.
+type Scope struct {
+	// MaxDepth is the number of child page and link hops followed from a root page. A negative value means no limit.
+	MaxDepth int
+	// FollowLinks says which linked pages are crawled. The zero value follows every link.
+	FollowLinks LinkPolicy
+	// MaxPages is the most pages exported in a run, including the roots. Zero means no limit.
+	MaxPages int
+	// Include, when not empty, only crawls pages whose title or ID matches one of the patterns.
+	Include []string
+	// Exclude skips pages whose title or ID matches one of the patterns.
+	Exclude []string
+}
+
+// DefaultScope crawls everything reachable from the root pages.
+func DefaultScope() Scope {
+	return Scope{MaxDepth: -1, FollowLinks: FollowAll}
+}
+
+// skipReason says why a page found at depth is not crawled, or is empty when it is. It leaves
+// aside whether the page is in the same tree, which needs a request to the API.
+func (s Scope) skipReason(ref format.PageRef, depth int) string {
+	switch {
+	case s.MaxDepth >= 0 && depth > s.MaxDepth:
+		return "deeper than the maximum depth"
+	case ref.Linked && s.FollowLinks == FollowNone:
+		return "links are not followed"
+	case len(s.Include) > 0 && !matchesAny(s.Include, ref):
+		return "not included"
+	case matchesAny(s.Exclude, ref):
+		return "excluded"
+	}
+	return ""
+}
+
+// matchesAny reports whether the page's title or ID matches one of the patterns. Patterns
+// are shell globs as understood by path.Match, and are compared case-insensitively. IDs may
+// be written with or without dashes.
+func matchesAny(patterns []string, ref format.PageRef) bool {
+	title := strings.ToLower(ref.Title)
+	for _, pattern := range patterns {
+		if api.NormalizeID(pattern) == api.NormalizeID(ref.ID) {
+			return true
+		}
+		if matched, err := path.Match(strings.ToLower(pattern), title); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// inTree reports whether a page is one of the root pages or underneath one, by walking up its parents.
+func (c *Crawler) inTree(pageID string) bool {
+	id := pageID
+	for i := 0; i < maxAncestors && id != ""; i++ {
+		if c.roots[api.NormalizeID(id)] {
+			return true
+		}
+		block, err := api.FetchBlock(c.API, id, c.BearerToken)
+		if err != nil {
+			slog.Warn("error fetching parent", "page_id", id, "err", err)
+			return false
+		}
+		if block.Parent == nil {
+			return false
+		}
+		id = block.Parent.ID()
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"testing"
+
+	"github.com/s-kngstn/notionsync/format"
+)
+
+func TestParseLinkPolicy(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  LinkPolicy
+		expectErr bool
+	}{
+		{"none", FollowNone, false},
+		{"Same-Tree", FollowSameTree, false},
+		{"all", FollowAll, false},
+		{"", FollowAll, false},
+		{"some", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			policy, err := ParseLinkPolicy(tt.input)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error but did not get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if policy != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, policy)
+			}
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	child := format.PageRef{ID: "1a2b3c4d-0000-1111-2222-333344445555", Title: "Meeting Notes"}
+	linked := format.PageRef{ID: "linked", Title: "Roadmap", Linked: true}
+
+	tests := []struct {
+		name     string
+		scope    Scope
+		ref      format.PageRef
+		depth    int
+		expected bool
+	}{
+		{"default scope", DefaultScope(), linked, 10, true},
+		{"within max depth", Scope{MaxDepth: 2, FollowLinks: FollowAll}, child, 2, true},
+		{"beyond max depth", Scope{MaxDepth: 2, FollowLinks: FollowAll}, child, 3, false},
+		{"links not followed", Scope{MaxDepth: -1, FollowLinks: FollowNone}, linked, 1, false},
+		{"children followed without links", Scope{MaxDepth: -1, FollowLinks: FollowNone}, child, 1, true},
+		{"included by title", Scope{MaxDepth: -1, Include: []string{"meeting*"}}, child, 1, true},
+		{"not included", Scope{MaxDepth: -1, Include: []string{"meeting*"}}, linked, 1, false},
+		{"excluded by title", Scope{MaxDepth: -1, Exclude: []string{"Road?ap"}}, linked, 1, false},
+		{"excluded by compact ID", Scope{MaxDepth: -1, Exclude: []string{"1a2b3c4d000011112222333344445555"}}, child, 1, false},
+		{"exclude wins over include", Scope{MaxDepth: -1, Include: []string{"*"}, Exclude: []string{"roadmap"}}, linked, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := tt.scope.skipReason(tt.ref, tt.depth); (reason == "") != tt.expected {
+				t.Errorf("skipReason() = %q, want the page allowed: %v", reason, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+)
+
+// StateFileName is the name of the file the state of a crawl is saved to, kept in the output directory.
+const StateFileName = ".notionsync-state.json"
+
+// stateSaveInterval is the least time between two saves of the state while a crawl runs.
+const stateSaveInterval = time.Second
+
+// State is how far a crawl got, saved as it runs so that an interrupted crawl can be resumed.
+// Every page in it was visited: the queued and failed pages are exported again when the crawl
+// is resumed, and the completed ones are not. Paths are relative to the directory it is kept in.
+type State struct {
+	// StartedAt is when the crawl first started, however many times it was resumed since.
+	StartedAt time.Time `json:"started_at"`
+	// Roots are the compact IDs of the pages the crawl started from.
+	Roots []string `json:"roots"`
+	// Queue holds the pages waiting to be exported, including the ones being exported when the state was saved.
+	Queue []SavedTask `json:"queue"`
+	// Failed holds the pages that could not be exported.
+	Failed []SavedTask `json:"failed"`
+	// Completed holds the pages that were exported.
+	Completed []SavedPage `json:"completed"`
+}
+
+// SavedTask is a Task in a saved State.
+type SavedTask struct {
+	PageID   string         `json:"page_id"`
+	Name     string         `json:"name"`
+	Dir      string         `json:"dir"`
+	Position int            `json:"position,omitempty"`
+	Depth    int            `json:"depth,omitempty"`
+	Settings *SavedSettings `json:"settings,omitempty"`
+}
+
+// SavedSettings are the Settings of a root page in a saved State. The options that are the same
+// for every root, such as the dialect, come from the crawler resuming the crawl.
+type SavedSettings struct {
+	Flavour   format.Flavour `json:"flavour"`
+	AssetDir  string         `json:"asset_dir,omitempty"`
+	LinkedDir string         `json:"linked_dir"`
+	MaxDepth  int            `json:"max_depth"`
+}
+
+// SavedPage is a page that was exported, in a saved State.
+type SavedPage struct {
+	PageID string `json:"page_id"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+}
+
+// LoadState reads the state of a crawl from a directory. It returns nil when the directory has none.
+func LoadState(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StateFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading crawl state: %w", err)
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing crawl state %s: %w", filepath.Join(dir, StateFileName), err)
+	}
+	return state, nil
+}
+
+// Save writes the state into a directory.
+func (s *State) Save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding crawl state: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, StateFileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing crawl state: %w", err)
+	}
+	return nil
+}
+
+// RemoveState deletes the state of a crawl from a directory, once there is nothing left to resume.
+func RemoveState(dir string) error {
+	err := os.Remove(filepath.Join(dir, StateFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing crawl state: %w", err)
+	}
+	return nil
+}
+
+// Restore sets the crawler up to carry on from a saved state, with the paths in it relative to dir.
+// The completed pages are claimed and reported as exported without being fetched again, and the
+// queued and failed pages are exported by the next Run, along with the roots it is given.
+func (c *Crawler) Restore(state *State, dir string) {
+	for _, page := range state.Completed {
+		path := absPath(dir, page.Path)
+		c.Registry.Claim(page.PageID)
+		c.Registry.SetPath(page.PageID, path)
+		c.Report.Add(PageResult{PageID: page.PageID, Name: page.Name, Status: Exported, Path: path})
+	}
+
+	// Pages crawled from the same root share its settings
+	settings := make(map[SavedSettings]*Settings)
+	for _, saved := range append(state.Queue, state.Failed...) {
+		task := Task{
+			PageID:   saved.PageID,
+			Name:     saved.Name,
+			Dir:      absPath(dir, saved.Dir),
+			Position: saved.Position,
+			Depth:    saved.Depth,
+		}
+		if saved.Settings != nil {
+			if _, ok := settings[*saved.Settings]; !ok {
+				opts := c.Options
+				opts.Flavour = saved.Settings.Flavour
+				opts.AssetDir = ""
+				if saved.Settings.AssetDir != "" {
+					opts.AssetDir = absPath(dir, saved.Settings.AssetDir)
+				}
+				settings[*saved.Settings] = &Settings{
+					Options:   opts,
+					LinkedDir: absPath(dir, saved.Settings.LinkedDir),
+					MaxDepth:  saved.Settings.MaxDepth,
+				}
+			}
+			task.Settings = settings[*saved.Settings]
+		}
+		c.restored = append(c.restored, task)
+	}
+	c.restoredRoots = state.Roots
+	c.startedAt = state.StartedAt
+}
+
+// state takes a snapshot of the crawl, with paths relative to dir. A page being exported is saved
+// as queued until it is done, so that the pages it refers to are not lost if the crawl stops before
+// they are queued.
+func (c *Crawler) state(dir string) *State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := &State{StartedAt: c.startedAt, Roots: []string{}, Queue: []SavedTask{}, Failed: []SavedTask{}, Completed: []SavedPage{}}
+	for id := range c.roots {
+		state.Roots = append(state.Roots, id)
+	}
+	sort.Strings(state.Roots)
+	for _, task := range c.running {
+		state.Queue = append(state.Queue, saveTask(task, dir))
+	}
+	sort.Slice(state.Queue, func(i, j int) bool { return state.Queue[i].PageID < state.Queue[j].PageID })
+	for _, task := range c.queue {
+		state.Queue = append(state.Queue, saveTask(task, dir))
+	}
+	for _, task := range c.failed {
+		state.Failed = append(state.Failed, saveTask(task, dir))
+	}
+	sort.Slice(state.Failed, func(i, j int) bool { return state.Failed[i].PageID < state.Failed[j].PageID })
+
+	for id, path := range c.Registry.Written() {
+		if _, ok := c.running[id]; ok {
+			continue
+		}
+		result, _ := c.Report.Result(id)
+		state.Completed = append(state.Completed, SavedPage{PageID: id, Name: result.Name, Path: relPath(dir, path)})
+	}
+	sort.Slice(state.Completed, func(i, j int) bool { return state.Completed[i].Path < state.Completed[j].Path })
+	return state
+}
+
+// saveState saves a snapshot of the crawl into StateDir. A crawl that cannot save its state carries on regardless.
+func (c *Crawler) saveState() {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if err := c.state(c.StateDir).Save(c.StateDir); err != nil {
+		c.stateErrOnce.Do(func() {
+			slog.Warn("error saving crawl state, the crawl cannot be resumed", "err", err)
+		})
+	}
+}
+
+func saveTask(task Task, dir string) SavedTask {
+	saved := SavedTask{
+		PageID:   task.PageID,
+		Name:     task.Name,
+		Dir:      relPath(dir, task.Dir),
+		Position: task.Position,
+		Depth:    task.Depth,
+	}
+	if task.Settings != nil {
+		saved.Settings = &SavedSettings{
+			Flavour:   task.Settings.Options.Flavour,
+			LinkedDir: relPath(dir, task.Settings.LinkedDir),
+			MaxDepth:  task.Settings.MaxDepth,
+		}
+		if task.Settings.Options.AssetDir != "" {
+			saved.Settings.AssetDir = relPath(dir, task.Settings.Options.AssetDir)
+		}
+	}
+	return saved
+}
+
+// relPath makes path relative to dir with forward slashes, keeping it as it is when it cannot be.
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// absPath turns a path saved by relPath back into one that can be opened.
+func absPath(dir, path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,149 @@
+package crawl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/format"
+)
+
+func TestCrawlerSavesState(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":  {childPage("child", "Child Page"), childPage("gone", "Gone")},
+			"child": {},
+		},
+		titles: map[string]string{"root": "Root"},
+	}
+
+	dir := t.TempDir()
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.StateDir = dir
+	crawler.Run([]Task{{PageID: "root", Name: "root", Dir: dir}})
+
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if state == nil {
+		t.Fatalf("Expected the state to be saved")
+	}
+	if !state.StartedAt.Equal(crawler.StartedAt()) {
+		t.Errorf("Expected the state to start at %v, got %v", crawler.StartedAt(), state.StartedAt)
+	}
+	if expected := []string{"root"}; !reflect.DeepEqual(state.Roots, expected) {
+		t.Errorf("Expected roots %v, got %v", expected, state.Roots)
+	}
+	if len(state.Queue) != 0 {
+		t.Errorf("Expected nothing left in the queue, got %+v", state.Queue)
+	}
+	expectedFailed := []SavedTask{{PageID: "gone", Name: "gone", Dir: ".", Position: 2, Depth: 1}}
+	if !reflect.DeepEqual(state.Failed, expectedFailed) {
+		t.Errorf("Expected failed pages %+v, got %+v", expectedFailed, state.Failed)
+	}
+	expectedCompleted := []SavedPage{
+		{PageID: "child", Name: "child-page", Path: "child-page.md"},
+		{PageID: "root", Name: "root", Path: "root.md"},
+	}
+	if !reflect.DeepEqual(state.Completed, expectedCompleted) {
+		t.Errorf("Expected completed pages %+v, got %+v", expectedCompleted, state.Completed)
+	}
+
+	failed := crawler.Failed()
+	if len(failed) != 1 || failed[0].PageID != "gone" {
+		t.Errorf("Expected the missing page to have failed, got %+v", failed)
+	}
+
+	if err := RemoveState(dir); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if state, err := LoadState(dir); state != nil || err != nil {
+		t.Errorf("Expected no state after removing it, got %+v, %v", state, err)
+	}
+}
+
+func TestCrawlerStateKeepsRunningPagesQueued(t *testing.T) {
+	dir := t.TempDir()
+	crawler := NewCrawler(&mockNotionAPI{}, "test-token", format.Options{}, dir)
+	crawler.running = map[string]Task{"child": {PageID: "child", Name: "child", Dir: dir}}
+
+	// A page that was written but whose child pages may not have been queued yet is not completed
+	crawler.Registry.Claim("child")
+	crawler.Registry.SetPath("child", filepath.Join(dir, "child.md"))
+
+	state := crawler.state(dir)
+	if len(state.Completed) != 0 {
+		t.Errorf("Expected no completed pages, got %+v", state.Completed)
+	}
+	if expected := []SavedTask{{PageID: "child", Name: "child", Dir: "."}}; !reflect.DeepEqual(state.Queue, expected) {
+		t.Errorf("Expected queue %+v, got %+v", expected, state.Queue)
+	}
+}
+
+func TestCrawlerRestore(t *testing.T) {
+	mockAPI := &mockNotionAPI{
+		children: map[string][]api.Block{
+			"root":   {childPage("child", "Child Page")},
+			"child":  {childPage("nested", "Nested")},
+			"nested": {linkToPage("root")},
+			"docs":   {},
+		},
+		titles: map[string]string{"root": "Root"},
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "root.md"), []byte("# Root\n"), 0644)
+	startedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	state := &State{
+		StartedAt: startedAt,
+		Roots:     []string{"root"},
+		Queue:     []SavedTask{{PageID: "child", Name: "child-page", Dir: ".", Position: 1, Depth: 1}},
+		Failed: []SavedTask{{
+			PageID:   "docs",
+			Name:     "docs",
+			Dir:      "content/docs",
+			Settings: &SavedSettings{Flavour: format.Hugo, LinkedDir: "content/docs", MaxDepth: 2},
+		}},
+		Completed: []SavedPage{{PageID: "root", Name: "root", Path: "root.md"}},
+	}
+
+	crawler := NewCrawler(mockAPI, "test-token", format.Options{}, dir)
+	crawler.StateDir = dir
+	crawler.Restore(state, dir)
+	crawler.Run(nil)
+
+	// The completed page is not fetched again, even though a link leads back to it
+	if mockAPI.fetches["root"] != 0 {
+		t.Errorf("Expected the completed page not to be fetched again")
+	}
+	for _, id := range []string{"child", "nested", "docs"} {
+		if mockAPI.fetches[id] != 1 {
+			t.Errorf("Expected page %s to be fetched once, was fetched %d times", id, mockAPI.fetches[id])
+		}
+	}
+	readFile(t, filepath.Join(dir, "child-page.md"))
+	readFile(t, filepath.Join(dir, "nested.md"))
+	// The failed page is exported with the settings of its root
+	if content := readFile(t, filepath.Join(dir, "content", "docs", "docs.md")); !strings.HasPrefix(content, "---\n") {
+		t.Errorf("Expected the page to be written with front matter, got %q", content)
+	}
+
+	if !crawler.StartedAt().Equal(startedAt) {
+		t.Errorf("Expected the crawl to keep its start time %v, got %v", startedAt, crawler.StartedAt())
+	}
+	if count := crawler.Report.Count(Exported); count != 4 {
+		t.Errorf("Expected 4 pages reported as exported, got %d", count)
+	}
+	saved, err := LoadState(dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(saved.Completed) != 4 || len(saved.Queue) != 0 || len(saved.Failed) != 0 {
+		t.Errorf("Expected every page to be completed, got %+v", saved)
+	}
+}
@@ -0,0 +1,659 @@
+// Package fakenotion is an in-process stand-in for the Notion API, serving an in-memory tree of
+// pages, databases and blocks over HTTP, so that the API client, the crawler and the commands can
+// be tested end to end without a real workspace. Faults and latency can be injected to test how
+// rate limits, server errors and slow responses are handled.
+package fakenotion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+// MaxPageSize is the most results the server returns in a single response, as with Notion.
+const MaxPageSize = 100
+
+// editTime is when the first object was created. Every change moves the clock on a minute, so that
+// edit times are deterministic but still tell changes apart at Notion's one minute precision.
+var editTime = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// Server is a fake Notion API. Its URL is the address of the server, and BaseURL the address
+// the API client should send requests to. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+	// Token is the bearer token requests must have. Others get 401 Unauthorized.
+	Token string
+	// Workspace is the name of the workspace the bot user belongs to.
+	Workspace string
+
+	mu       sync.Mutex
+	objects  map[string]*object
+	order    []string
+	seq      int
+	edits    int
+	faults   []*Fault
+	latency  time.Duration
+	requests []string
+}
+
+// object is a page, database or block in the tree, keyed by its ID in compact form.
+type object struct {
+	block api.Block
+	// page is set for pages and databases, which are blocks as well.
+	page     *api.Page
+	parent   string
+	children []string
+	// entries are the pages of a database, which are not among its block children.
+	entries  []string
+	archived bool
+}
+
+// Fault makes the server fail requests rather than answer them.
+type Fault struct {
+	// Status is the status code to respond with, such as 429 or 500.
+	Status int
+	// Path limits the fault to requests whose path starts with it, such as /v1/blocks/ID/children.
+	// An empty path matches every request.
+	Path string
+	// Times is the number of requests to fail, after which the fault is cleared. Zero fails them all.
+	Times int
+	// RetryAfter is the delay in seconds sent with 429 responses.
+	RetryAfter int
+}
+
+// New starts a server with an empty workspace, which only accepts token. Close it once done.
+func New(token string) *Server {
+	s := &Server{
+		Token:     token,
+		Workspace: "Fake Workspace",
+		objects:   make(map[string]*object),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// BaseURL is the address to point an API client at.
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// APIClient creates an API client that talks to the server.
+func (s *Server) APIClient() *api.NotionApiClient {
+	return s.APIClientWith(s.Client())
+}
+
+// APIClientWith creates an API client that talks to the server through client, such as a rate limited one.
+func (s *Server) APIClientWith(client api.HttpClientInterface) *api.NotionApiClient {
+	apiClient := api.NewNotionApiClient(client)
+	apiClient.BaseURL = s.BaseURL()
+	return apiClient
+}
+
+// Inject adds a fault, which takes effect from the next request.
+func (s *Server) Inject(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every fault that is still in effect.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the requests received so far, failed ones included, as the method and the path with its query.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// AddPage adds a page with blocks as its content, and returns its ID. The page is a child page of
+// parentID, or at the top of the workspace when parentID is empty.
+func (s *Server) AddPage(parentID, title string, blocks ...api.Block) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.add(parentID, api.Block{Type: "child_page", ChildPage: &api.ChildPage{Title: title}})
+	obj.page = &api.Page{
+		Object: "page",
+		Properties: map[string]api.Property{
+			"title": {ID: "title", Type: "title", Title: richText(title)},
+		},
+	}
+	s.appendBlocks(obj, blocks)
+	return obj.block.ID
+}
+
+// AddDatabase adds an empty database as a child of parentID, or at the top of the workspace when
+// parentID is empty, and returns its ID.
+func (s *Server) AddDatabase(parentID, title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj := s.add(parentID, api.Block{Type: "child_database"})
+	obj.page = &api.Page{Object: "database", DatabaseTitle: richText(title)}
+	return obj.block.ID
+}
+
+// AddDatabaseEntry adds a page with blocks as its content to a database, and returns its ID.
+func (s *Server) AddDatabaseEntry(databaseID, title string, blocks ...api.Block) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	database := s.objects[api.NormalizeID(databaseID)]
+	if database == nil || database.page == nil || database.page.Object != "database" {
+		panic(fmt.Sprintf("fakenotion: no database %s", databaseID))
+	}
+	obj := s.newObject(api.Block{Type: "child_page", ChildPage: &api.ChildPage{Title: title}})
+	obj.block.Parent = &api.Parent{Type: "database_id", DatabaseID: database.block.ID}
+	obj.parent = database.block.ID
+	obj.page = &api.Page{
+		Object: "page",
+		Properties: map[string]api.Property{
+			"Name": {ID: "title", Type: "title", Title: richText(title)},
+		},
+	}
+	database.entries = append(database.entries, obj.block.ID)
+	s.appendBlocks(obj, blocks)
+	return obj.block.ID
+}
+
+// AddBlocks adds blocks to the end of a page or block's content, and returns their IDs.
+func (s *Server) AddBlocks(parentID string, blocks ...api.Block) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := s.objects[api.NormalizeID(parentID)]
+	if parent == nil {
+		panic(fmt.Sprintf("fakenotion: no block %s", parentID))
+	}
+	return s.appendBlocks(parent, blocks)
+}
+
+// Children returns the content of a page or block as it is now, to check what was written to it.
+func (s *Server) Children(blockID string) []api.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := s.objects[api.NormalizeID(blockID)]
+	if parent == nil {
+		return nil
+	}
+	blocks := make([]api.Block, 0, len(parent.children))
+	for _, id := range parent.children {
+		blocks = append(blocks, s.objects[api.NormalizeID(id)].block)
+	}
+	return blocks
+}
+
+// newObject creates an object with a new ID, which is not part of the tree yet.
+func (s *Server) newObject(block api.Block) *object {
+	s.seq++
+	block.ID = fmt.Sprintf("%08x-0000-4000-8000-%012x", s.seq, s.seq)
+	block.LastEditedTime = s.touch()
+	obj := &object{block: block}
+	key := api.NormalizeID(block.ID)
+	s.objects[key] = obj
+	s.order = append(s.order, key)
+	return obj
+}
+
+// add creates an object as the last child of parentID, or at the top of the workspace when it is empty.
+func (s *Server) add(parentID string, block api.Block) *object {
+	if parentID == "" {
+		obj := s.newObject(block)
+		obj.block.Parent = &api.Parent{Type: "workspace", Workspace: true}
+		return obj
+	}
+	parent := s.objects[api.NormalizeID(parentID)]
+	if parent == nil {
+		panic(fmt.Sprintf("fakenotion: no page %s", parentID))
+	}
+	obj := s.newObject(block)
+	s.attach(parent, obj)
+	return obj
+}
+
+// attach makes obj the last child of parent.
+func (s *Server) attach(parent, obj *object) {
+	if parent.page != nil {
+		obj.block.Parent = &api.Parent{Type: "page_id", PageID: parent.block.ID}
+	} else {
+		obj.block.Parent = &api.Parent{Type: "block_id", BlockID: parent.block.ID}
+	}
+	obj.parent = parent.block.ID
+	parent.children = append(parent.children, obj.block.ID)
+	parent.block.HasChildren = true
+	parent.block.LastEditedTime = s.touch()
+}
+
+func (s *Server) appendBlocks(parent *object, blocks []api.Block) []string {
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		obj := s.newObject(block)
+		s.attach(parent, obj)
+		ids = append(ids, obj.block.ID)
+	}
+	return ids
+}
+
+// touch moves the clock on, and returns the new edit time.
+func (s *Server) touch() string {
+	s.edits++
+	return editTime.Add(time.Duration(s.edits) * time.Minute).Format("2006-01-02T15:04:05.000Z")
+}
+
+// lookup returns the object with id, unless there is none or it has been deleted.
+func (s *Server) lookup(id string) *object {
+	obj := s.objects[api.NormalizeID(id)]
+	if obj == nil || obj.archived {
+		return nil
+	}
+	return obj
+}
+
+func richText(text string) []api.RichText {
+	return []api.RichText{{Type: "text", Text: api.Text{Content: text}, PlainText: text}}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	latency := s.latency
+	fault := s.fault(r.URL.Path)
+	s.mu.Unlock()
+
+	time.Sleep(latency)
+	if fault != nil {
+		if fault.Status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+			writeError(w, fault.Status, "rate_limited", "You have been rate limited.")
+			return
+		}
+		writeError(w, fault.Status, "internal_server_error", "Injected fault.")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "API token is invalid.")
+		return
+	}
+	if r.Header.Get("Notion-Version") == "" {
+		writeError(w, http.StatusBadRequest, "missing_version", "Notion-Version header failed validation.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	switch {
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "blocks":
+		s.getBlock(w, parts[1])
+	case r.Method == "DELETE" && len(parts) == 2 && parts[0] == "blocks":
+		s.deleteBlock(w, parts[1])
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		s.getChildren(w, r, parts[1])
+	case r.Method == "PATCH" && len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		s.appendChildren(w, r, parts[1])
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "pages":
+		s.getPage(w, parts[1])
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "databases" && parts[2] == "query":
+		s.queryDatabase(w, r, parts[1])
+	case r.Method == "POST" && len(parts) == 1 && parts[0] == "search":
+		s.search(w, r)
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "users" && parts[1] == "me":
+		writeJSON(w, api.User{Object: "user", ID: "00000000-0000-4000-8000-000000000b07", Name: "Fake Integration", Type: "bot", Bot: &api.Bot{WorkspaceName: s.Workspace}})
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request_url", "Invalid request URL.")
+	}
+}
+
+// fault returns the fault that applies to a request for path, counting the request against it.
+func (s *Server) fault(path string) *Fault {
+	for i, fault := range s.faults {
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// blockResponse is a block as the API returns it, with the fields the client has no use for.
+type blockResponse struct {
+	Object string `json:"object"`
+	api.Block
+	ChildDatabase *childDatabase `json:"child_database,omitempty"`
+	Archived      bool           `json:"archived"`
+}
+
+type childDatabase struct {
+	Title string `json:"title"`
+}
+
+func (s *Server) blockResponse(obj *object) blockResponse {
+	resp := blockResponse{Object: "block", Block: obj.block, Archived: obj.archived}
+	if obj.block.Type == "child_database" {
+		resp.ChildDatabase = &childDatabase{Title: obj.page.Title()}
+	}
+	return resp
+}
+
+// databaseResponse is a database as the API returns it. Unlike a page, its properties are the
+// schema of its entries' properties rather than values, and its title is a field of its own.
+type databaseResponse struct {
+	Object         string                    `json:"object"`
+	ID             string                    `json:"id"`
+	CreatedTime    string                    `json:"created_time"`
+	LastEditedTime string                    `json:"last_edited_time"`
+	URL            string                    `json:"url"`
+	Parent         *api.Parent               `json:"parent"`
+	Title          []api.RichText            `json:"title"`
+	Description    []api.RichText            `json:"description"`
+	IsInline       bool                      `json:"is_inline"`
+	Archived       bool                      `json:"archived"`
+	Properties     map[string]map[string]any `json:"properties"`
+}
+
+// databaseSchema is the schema every database in the fake has: the title property its entries are
+// named by, and a multi-select with no options yet.
+func databaseSchema() map[string]map[string]any {
+	return map[string]map[string]any{
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": map[string]any{}},
+		"Tags": {"id": "%3AtLk", "name": "Tags", "type": "multi_select", "multi_select": map[string]any{"options": []any{}}},
+	}
+}
+
+// pageResponse fills in the fields a page or database shares with its block.
+func (s *Server) pageResponse(obj *object) any {
+	createdTime := editTime.Format("2006-01-02T15:04:05.000Z")
+	url := "https://www.notion.so/" + api.NormalizeID(obj.block.ID)
+	if obj.page.Object == "database" {
+		return databaseResponse{
+			Object:         "database",
+			ID:             obj.block.ID,
+			CreatedTime:    createdTime,
+			LastEditedTime: obj.block.LastEditedTime,
+			URL:            url,
+			Parent:         obj.block.Parent,
+			Title:          obj.page.DatabaseTitle,
+			Description:    []api.RichText{},
+			Archived:       obj.archived,
+			Properties:     databaseSchema(),
+		}
+	}
+	page := *obj.page
+	page.ID = obj.block.ID
+	page.CreatedTime = createdTime
+	page.LastEditedTime = obj.block.LastEditedTime
+	page.URL = url
+	page.Parent = obj.block.Parent
+	return page
+}
+
+func (s *Server) getBlock(w http.ResponseWriter, id string) {
+	obj := s.lookup(id)
+	if obj == nil {
+		notFound(w, id)
+		return
+	}
+	writeJSON(w, s.blockResponse(obj))
+}
+
+func (s *Server) deleteBlock(w http.ResponseWriter, id string) {
+	obj := s.lookup(id)
+	if obj == nil {
+		notFound(w, id)
+		return
+	}
+	obj.archived = true
+	obj.block.LastEditedTime = s.touch()
+	if parent := s.objects[api.NormalizeID(obj.parent)]; parent != nil {
+		for i, childID := range parent.children {
+			if childID == obj.block.ID {
+				parent.children = append(parent.children[:i:i], parent.children[i+1:]...)
+				break
+			}
+		}
+		parent.block.HasChildren = len(parent.children) > 0
+		parent.block.LastEditedTime = s.touch()
+	}
+	writeJSON(w, s.blockResponse(obj))
+}
+
+func (s *Server) getChildren(w http.ResponseWriter, r *http.Request, id string) {
+	obj := s.lookup(id)
+	if obj == nil {
+		notFound(w, id)
+		return
+	}
+	pageSize, err := parsePageSize(r.URL.Query().Get("page_size"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	results, hasMore, next := paginate(obj.children, r.URL.Query().Get("start_cursor"), pageSize)
+	blocks := make([]blockResponse, 0, len(results))
+	for _, childID := range results {
+		blocks = append(blocks, s.blockResponse(s.objects[api.NormalizeID(childID)]))
+	}
+	writeList(w, blocks, hasMore, next)
+}
+
+func (s *Server) appendChildren(w http.ResponseWriter, r *http.Request, id string) {
+	obj := s.lookup(id)
+	if obj == nil {
+		notFound(w, id)
+		return
+	}
+	var body struct {
+		Children []api.Block `json:"children"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "Error parsing JSON body.")
+		return
+	}
+	if len(body.Children) > MaxPageSize {
+		writeError(w, http.StatusBadRequest, "validation_error",
+			fmt.Sprintf("body.children.length should be ≤ `%d`, instead was `%d`.", MaxPageSize, len(body.Children)))
+		return
+	}
+	for i := range body.Children {
+		fillPlainText(&body.Children[i])
+	}
+	ids := s.appendBlocks(obj, body.Children)
+	blocks := make([]blockResponse, 0, len(ids))
+	for _, childID := range ids {
+		blocks = append(blocks, s.blockResponse(s.objects[api.NormalizeID(childID)]))
+	}
+	writeList(w, blocks, false, "")
+}
+
+// fillPlainText sets the plain text of the rich text in a block that was sent to the server, which
+// only has its content, as Notion does.
+func fillPlainText(block *api.Block) {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return
+	}
+	data, err = json.Marshal(withPlainText(tree))
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, block)
+}
+
+func withPlainText(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if text, ok := v["text"].(map[string]any); ok && v["plain_text"] == "" {
+			v["plain_text"] = text["content"]
+		}
+		for key, value := range v {
+			v[key] = withPlainText(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = withPlainText(value)
+		}
+	}
+	return v
+}
+
+func (s *Server) getPage(w http.ResponseWriter, id string) {
+	obj := s.lookup(id)
+	if obj == nil || obj.page == nil || obj.page.Object != "page" {
+		notFound(w, id)
+		return
+	}
+	writeJSON(w, s.pageResponse(obj))
+}
+
+// readListRequest reads the body of a database query or search, which only has its paging,
+// query and filter looked at.
+func (s *Server) readListRequest(w http.ResponseWriter, r *http.Request) (api.SearchRequest, bool) {
+	var request api.SearchRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", "Error parsing JSON body.")
+			return request, false
+		}
+	}
+	if request.PageSize < 0 || request.PageSize > MaxPageSize {
+		writeError(w, http.StatusBadRequest, "validation_error", fmt.Sprintf("body.page_size should be ≤ `%d`.", MaxPageSize))
+		return request, false
+	}
+	if request.PageSize == 0 {
+		request.PageSize = MaxPageSize
+	}
+	return request, true
+}
+
+func (s *Server) queryDatabase(w http.ResponseWriter, r *http.Request, id string) {
+	obj := s.lookup(id)
+	if obj == nil || obj.page == nil || obj.page.Object != "database" {
+		notFound(w, id)
+		return
+	}
+	request, ok := s.readListRequest(w, r)
+	if !ok {
+		return
+	}
+	var entries []string
+	for _, entryID := range obj.entries {
+		if s.lookup(entryID) != nil {
+			entries = append(entries, entryID)
+		}
+	}
+	results, hasMore, next := paginate(entries, request.StartCursor, request.PageSize)
+	writeList(w, s.pages(results), hasMore, next)
+}
+
+// search matches the query against titles, ignoring case, and returns pages and databases in the order they were added.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	request, ok := s.readListRequest(w, r)
+	if !ok {
+		return
+	}
+	query := strings.ToLower(request.Query)
+	var matches []string
+	for _, key := range s.order {
+		obj := s.objects[key]
+		if obj.archived || obj.page == nil {
+			continue
+		}
+		if request.Filter != nil && request.Filter.Value != obj.page.Object {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(obj.page.Title()), query) {
+			continue
+		}
+		matches = append(matches, obj.block.ID)
+	}
+	results, hasMore, next := paginate(matches, request.StartCursor, request.PageSize)
+	writeList(w, s.pages(results), hasMore, next)
+}
+
+func (s *Server) pages(ids []string) []any {
+	pages := make([]any, 0, len(ids))
+	for _, id := range ids {
+		pages = append(pages, s.pageResponse(s.objects[api.NormalizeID(id)]))
+	}
+	return pages
+}
+
+func parsePageSize(value string) (int, error) {
+	if value == "" {
+		return MaxPageSize, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 || size > MaxPageSize {
+		return 0, fmt.Errorf("page_size should be a number between 1 and %d, instead was `%s`.", MaxPageSize, value)
+	}
+	return size, nil
+}
+
+// paginate returns the IDs from the one at cursor on, pageSize at most, and the cursor of the next
+// page when there are more. Like Notion, the cursor is the ID of the first result on the page.
+func paginate(ids []string, cursor string, pageSize int) ([]string, bool, string) {
+	start := 0
+	if cursor != "" {
+		start = len(ids)
+		for i, id := range ids {
+			if api.NormalizeID(id) == api.NormalizeID(cursor) {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+pageSize, len(ids))
+	if end < len(ids) {
+		return ids[start:end], true, ids[end]
+	}
+	return ids[start:end], false, ""
+}
+
+func writeList(w http.ResponseWriter, results any, hasMore bool, next string) {
+	var nextCursor *string
+	if hasMore {
+		nextCursor = &next
+	}
+	writeJSON(w, map[string]any{"object": "list", "results": results, "has_more": hasMore, "next_cursor": nextCursor})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(api.APIErrorResponse{Object: "error", Status: status, Code: code, Message: message})
+}
+
+func notFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, "object_not_found",
+		fmt.Sprintf("Could not find block with ID: %s. Make sure the relevant pages and databases are shared with your integration.", id))
+}
@@ -0,0 +1,272 @@
+package fakenotion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+)
+
+const token = "secret_fake"
+
+func paragraph(text string) api.Block {
+	return api.Block{Type: "paragraph", Paragraph: &api.Paragraph{RichText: richText(text)}}
+}
+
+func paragraphs(n int) []api.Block {
+	blocks := make([]api.Block, n)
+	for i := range blocks {
+		blocks[i] = paragraph(fmt.Sprint("Paragraph ", i))
+	}
+	return blocks
+}
+
+func countRequests(requests []string, prefix string) int {
+	count := 0
+	for _, request := range requests {
+		if strings.HasPrefix(request, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestChildrenPagination(t *testing.T) {
+	server := New(token)
+	defer server.Close()
+	pageID := server.AddPage("", "Long page", paragraphs(250)...)
+	client := server.APIClient()
+
+	results, err := client.GetNotionChildBlocks(pageID, token)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(results.Results) != 250 {
+		t.Fatalf("Expected 250 blocks, got %d", len(results.Results))
+	}
+	for i, block := range results.Results {
+		if text := block.Paragraph.RichText[0].PlainText; text != fmt.Sprint("Paragraph ", i) {
+			t.Fatalf("Expected block %d to be Paragraph %d, got %q", i, i, text)
+		}
+	}
+	if count := countRequests(server.Requests(), "GET /v1/blocks/"+pageID+"/children"); count != 3 {
+		t.Errorf("Expected 3 requests for the children, got %d", count)
+	}
+
+	title, err := client.GetNotionBlockTitle(pageID, token)
+	if err != nil || title != "Long page" {
+		t.Errorf("Expected the title Long page, got %q, %v", title, err)
+	}
+	page, err := client.GetNotionPage(pageID, token)
+	if err != nil || page.Title() != "Long page" || page.Parent.Type != "workspace" {
+		t.Errorf("Expected the page at the top of the workspace, got %+v, %v", page, err)
+	}
+}
+
+func TestSearchAndDatabases(t *testing.T) {
+	server := New(token)
+	defer server.Close()
+	wiki := server.AddPage("", "Team wiki")
+	server.AddPage(wiki, "Onboarding")
+	tasks := server.AddDatabase(wiki, "Tasks")
+	for i := 0; i < 3; i++ {
+		server.AddDatabaseEntry(tasks, fmt.Sprint("Task ", i), paragraph("To do"))
+	}
+	client := server.APIClient()
+
+	tests := []struct {
+		name     string
+		request  api.SearchRequest
+		expected []string
+	}{
+		{"everything", api.SearchRequest{}, []string{"Team wiki", "Onboarding", "Tasks", "Task 0", "Task 1", "Task 2"}},
+		{"query", api.SearchRequest{Query: "task"}, []string{"Tasks", "Task 0", "Task 1", "Task 2"}},
+		{"databases", api.SearchRequest{Filter: &api.SearchFilter{Property: "object", Value: "database"}}, []string{"Tasks"}},
+		{"paginated", api.SearchRequest{Query: "task", PageSize: 3}, []string{"Tasks", "Task 0", "Task 1", "Task 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var titles []string
+			request := tt.request
+			for {
+				results, err := client.Search(request, token)
+				if err != nil {
+					t.Fatalf("Did not expect an error but got one: %v", err)
+				}
+				for _, page := range results.Results {
+					titles = append(titles, page.Title())
+				}
+				if !results.HasMore {
+					break
+				}
+				request.StartCursor = results.NextCursor
+			}
+			if strings.Join(titles, ", ") != strings.Join(tt.expected, ", ") {
+				t.Errorf("Expected %v, got %v", tt.expected, titles)
+			}
+		})
+	}
+
+	// The client has no use for database queries, so the endpoint is checked with a plain request
+	req, _ := http.NewRequest("POST", server.BaseURL()+"/databases/"+tasks+"/query", strings.NewReader(`{"page_size": 2}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	defer resp.Body.Close()
+	var results api.SearchResponse
+	if err := decode(resp, &results); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(results.Results) != 2 || !results.HasMore || results.Results[0].Title() != "Task 0" {
+		t.Errorf("Expected the first two tasks and more to come, got %+v", results)
+	}
+
+	// Databases are sent the way Notion sends them, with a property schema and a title of their own
+	req, _ = http.NewRequest("POST", server.BaseURL()+"/search", strings.NewReader(`{"filter": {"property": "object", "value": "database"}}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	resp, err = server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	defer resp.Body.Close()
+	var raw struct {
+		Results []struct {
+			Title      []api.RichText                        `json:"title"`
+			Properties map[string]map[string]json.RawMessage `json:"properties"`
+		} `json:"results"`
+	}
+	if err := decode(resp, &raw); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(raw.Results) != 1 || len(raw.Results[0].Title) != 1 || raw.Results[0].Title[0].PlainText != "Tasks" {
+		t.Fatalf("Expected the database's title, got %+v", raw.Results)
+	}
+	if schema := string(raw.Results[0].Properties["Name"]["title"]); schema != "{}" {
+		t.Errorf("Expected the title property to be a schema, got %s", schema)
+	}
+
+	children, err := client.GetNotionChildBlocks(wiki, token)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(children.Results) != 2 || children.Results[1].Type != "child_database" {
+		t.Errorf("Expected the child page and the database, got %+v", children.Results)
+	}
+}
+
+func TestWrites(t *testing.T) {
+	server := New(token)
+	defer server.Close()
+	pageID := server.AddPage("", "Notes", paragraph("Old"))
+	client := server.APIClient()
+
+	before, _ := client.GetNotionBlock(pageID, token)
+	if err := client.AppendBlockChildren(pageID, paragraphs(150), token); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if count := countRequests(server.Requests(), "PATCH /v1/blocks/"+pageID+"/children"); count != 2 {
+		t.Errorf("Expected the blocks to be appended in 2 requests, got %d", count)
+	}
+	children := server.Children(pageID)
+	if len(children) != 151 || children[150].Paragraph.RichText[0].PlainText != "Paragraph 149" {
+		t.Fatalf("Expected the 150 blocks after the old one, got %d", len(children))
+	}
+	after, _ := client.GetNotionBlock(pageID, token)
+	if after.LastEditedTime <= before.LastEditedTime {
+		t.Errorf("Expected the page to be edited, got %s then %s", before.LastEditedTime, after.LastEditedTime)
+	}
+
+	if err := client.DeleteBlock(children[0].ID, token); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if children := server.Children(pageID); len(children) != 150 {
+		t.Errorf("Expected the deleted block to be gone, got %d blocks", len(children))
+	}
+	var apiErr *api.APIErrorResponse
+	if err := client.DeleteBlock(children[0].ID, token); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("Expected a 404 for a deleted block, got %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	server := New(token)
+	defer server.Close()
+	pageID := server.AddPage("", "Flaky", paragraph("Hello"))
+	children := "/v1/blocks/" + pageID + "/children"
+
+	tests := []struct {
+		name     string
+		fault    Fault
+		status   int
+		requests int
+	}{
+		{"rate limited then answered", Fault{Status: http.StatusTooManyRequests, Path: children, Times: 2}, 0, 3},
+		{"rate limited every time", Fault{Status: http.StatusTooManyRequests, Path: children}, http.StatusTooManyRequests, 6},
+		{"server error", Fault{Status: http.StatusInternalServerError, Path: children, Times: 1}, http.StatusInternalServerError, 1},
+		{"other paths", Fault{Status: http.StatusInternalServerError, Path: "/v1/search"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.ClearFaults()
+			server.Inject(tt.fault)
+			limited := api.NewRateLimitedClient(server.Client(), api.DefaultRequestsPerSecond)
+			limited.Interval = 0
+			client := server.APIClientWith(limited)
+			before := len(server.Requests())
+
+			_, err := client.GetNotionChildBlocks(pageID, token)
+			var apiErr *api.APIErrorResponse
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Errorf("Did not expect an error but got one: %v", err)
+			case tt.status != 0 && (!errors.As(err, &apiErr) || apiErr.Status != tt.status):
+				t.Errorf("Expected a %d error, got %v", tt.status, err)
+			}
+			if requests := len(server.Requests()) - before; requests != tt.requests {
+				t.Errorf("Expected %d requests, got %d", tt.requests, requests)
+			}
+		})
+	}
+}
+
+func TestLatencyAndAuth(t *testing.T) {
+	server := New(token)
+	defer server.Close()
+	pageID := server.AddPage("", "Slow")
+	server.SetLatency(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := server.APIClient().GetNotionBlock(pageID, token); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the response to take at least 50ms, took %s", elapsed)
+	}
+
+	var apiErr *api.APIErrorResponse
+	if _, err := server.APIClient().GetBotUser("wrong"); !errors.As(err, &apiErr) || apiErr.Code != "unauthorized" {
+		t.Errorf("Expected the wrong token to be unauthorized, got %v", err)
+	}
+	user, err := server.APIClient().GetBotUser(token)
+	if err != nil || user.Bot == nil || user.Bot.WorkspaceName != server.Workspace {
+		t.Errorf("Expected the bot user, got %+v, %v", user, err)
+	}
+}
+
+func decode(resp *http.Response, v any) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
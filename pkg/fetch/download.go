@@ -0,0 +1,41 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+)
+
+// DownloadTimeout is the longest a single download may take, so that a stalled download cannot hold up an export.
+const DownloadTimeout = 2 * time.Minute
+
+// downloadClient sends the download requests.
+var downloadClient = &http.Client{Timeout: DownloadTimeout}
+
+// DownloadFile downloads the file at fileURL and saves it to the specified file path, unless the file there is the same.
+// Files hosted by Notion have signed URLs that expire, so they need to be downloaded at export time.
+func DownloadFile(fileURL, filePath string) (atomicfile.Result, error) {
+	resp, err := downloadClient.Get(fileURL)
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download failed with status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error downloading file: %w", err)
+	}
+	// A download cut off halfway leaves any earlier copy in place rather than a truncated file
+	result, err := atomicfile.WriteIfChanged(filePath, data, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("error writing file: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+)
+
+func TestDownloadFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/image.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("png bytes"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	filePath := filepath.Join(dir, "image.png")
+	result, err := DownloadFile(server.URL+"/image.png", filePath)
+	if err != nil {
+		t.Fatalf("DownloadFile() returned an error: %v", err)
+	}
+	if result != atomicfile.Created {
+		t.Errorf("DownloadFile() = %v, want %v", result, atomicfile.Created)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if string(content) != "png bytes" {
+		t.Errorf("Downloaded content = %q, want %q", content, "png bytes")
+	}
+
+	// Downloading the same file again leaves it alone
+	if result, err := DownloadFile(server.URL+"/image.png", filePath); err != nil || result != atomicfile.Unchanged {
+		t.Errorf("DownloadFile() = %v, %v, want %v", result, err, atomicfile.Unchanged)
+	}
+
+	if _, err := DownloadFile(server.URL+"/missing.png", filepath.Join(dir, "missing.png")); err == nil {
+		t.Errorf("Expected an error for a missing file but did not get one")
+	}
+}
+
+func TestDownloadFileTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	original := downloadClient
+	downloadClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { downloadClient = original }()
+
+	if _, err := DownloadFile(server.URL+"/stalled.png", filepath.Join(t.TempDir(), "stalled.png")); err == nil {
+		t.Errorf("Expected a stalled download to time out")
+	}
+}
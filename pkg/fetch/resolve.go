@@ -0,0 +1,115 @@
+package fetch
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RefType is the kind of Notion object a Reference points to.
+type RefType string
+
+const (
+	PageRef     RefType = "page"
+	DatabaseRef RefType = "database"
+	BlockRef    RefType = "block"
+)
+
+// Reference is a Notion object identified by a URL or a bare ID.
+type Reference struct {
+	Type RefType
+	// ID is the ID of the object, in its dashed form.
+	ID string
+	// PageID is the page a block is on, set only for block references.
+	PageID string
+	// Name is the page name taken from the URL's slug, lower-cased. It is empty when the
+	// URL has no slug, or the reference is a bare ID.
+	Name string
+}
+
+var (
+	bareIDPattern = regexp.MustCompile(`^(?i)([0-9a-f]{32}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+	// slugIDPattern matches a path segment ending in an ID, like Meeting-Notes-0123456789abcdef0123456789abcdef
+	slugIDPattern = regexp.MustCompile(`^(?i)(?:(.*)-)?([0-9a-f]{32}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+)
+
+// Resolve turns a page URL or ID into a Reference. It accepts:
+//
+//   - bare IDs, with or without dashes
+//   - notion.so, notion.com and notion.site URLs, with or without the scheme and www
+//   - published pages on custom domains, since they keep the page ID in the path
+//   - peek URLs, where ?p= names the page open on top of a database
+//   - database view URLs, which have a ?v= view ID
+//   - #anchor links to a block on a page
+func Resolve(input string) (Reference, error) {
+	input = strings.TrimSpace(input)
+	if bareIDPattern.MatchString(input) {
+		return Reference{Type: PageRef, ID: FormatID(input)}, nil
+	}
+
+	if !strings.Contains(input, "://") && IsNotionHost(strings.SplitN(input, "/", 2)[0]) {
+		input = "https://" + input
+	}
+	parsedURL, err := url.Parse(input)
+	if err != nil {
+		return Reference{}, fmt.Errorf("error parsing URL: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" || parsedURL.Host == "" {
+		return Reference{}, fmt.Errorf("%q is not a Notion URL or page ID", input)
+	}
+
+	ref := Reference{Type: PageRef}
+	segments := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if matches := slugIDPattern.FindStringSubmatch(segments[i]); matches != nil {
+			ref.ID = FormatID(matches[2])
+			ref.Name = strings.ToLower(strings.Trim(matches[1], "-"))
+			break
+		}
+	}
+	if ref.ID == "" {
+		return Reference{}, fmt.Errorf("no page ID found in URL %s", input)
+	}
+
+	query := parsedURL.Query()
+	if peek := query.Get("p"); peek != "" {
+		if !bareIDPattern.MatchString(peek) {
+			return Reference{}, fmt.Errorf("invalid page ID %q in URL %s", peek, input)
+		}
+		// The path is the database the page was opened from
+		ref = Reference{Type: PageRef, ID: FormatID(peek)}
+	} else if query.Get("v") != "" {
+		ref.Type = DatabaseRef
+	}
+
+	if anchor := parsedURL.Fragment; anchor != "" && bareIDPattern.MatchString(anchor) {
+		ref = Reference{Type: BlockRef, ID: FormatID(anchor), PageID: ref.ID, Name: ref.Name}
+	}
+	return ref, nil
+}
+
+// IsNotionHost reports whether host is one of Notion's own domains.
+func IsNotionHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range []string{"notion.so", "notion.com", "notion.site"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatID returns an ID in the dashed form the API uses.
+func FormatID(id string) string {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if len(id) != 32 {
+		return id
+	}
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:]
+}
+
+// String describes the reference for messages, such as "page 0123abcd-...".
+func (r Reference) String() string {
+	return fmt.Sprintf("%s %s", r.Type, r.ID)
+}
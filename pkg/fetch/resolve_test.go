@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	const id = "3aa9e2a7-e3d2-4fe1-b92f-7fef71e05760"
+	const otherID = "f1ca8828-9819-4427-b92d-0af12d73633a"
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Reference
+		wantErr bool
+	}{
+		{"compact ID", "3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id}, false},
+		{"dashed ID", "3AA9E2A7-E3D2-4FE1-B92F-7FEF71E05760", Reference{Type: PageRef, ID: id}, false},
+		{"www.notion.so", "https://www.notion.so/samkingston/Tech-standup-3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id, Name: "tech-standup"}, false},
+		{"notion.so without www or scheme", "notion.so/Tech-standup-3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id, Name: "tech-standup"}, false},
+		{"notion.com", "https://www.notion.com/team/Roadmap-3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id, Name: "roadmap"}, false},
+		{"published notion.site page", "https://team.notion.site/Handbook-3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id, Name: "handbook"}, false},
+		{"custom domain", "https://docs.example.com/Getting-Started-3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id, Name: "getting-started"}, false},
+		{"no slug", "https://www.notion.so/3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{Type: PageRef, ID: id}, false},
+		{"dashed ID in path", "https://www.notion.so/c/3aa9e2a7-e3d2-4fe1-b92f-7fef71e05760", Reference{Type: PageRef, ID: id}, false},
+		{"database view", "https://www.notion.so/Tasks-3aa9e2a7e3d24fe1b92f7fef71e05760?v=0000000000000000000000000000000a", Reference{Type: DatabaseRef, ID: id, Name: "tasks"}, false},
+		{"peek", "https://www.notion.so/Tasks-3aa9e2a7e3d24fe1b92f7fef71e05760?v=0000000000000000000000000000000a&p=f1ca882898194427b92d0af12d73633a&pm=s", Reference{Type: PageRef, ID: otherID}, false},
+		{"block anchor", "https://www.notion.so/Tech-standup-3aa9e2a7e3d24fe1b92f7fef71e05760#f1ca882898194427b92d0af12d73633a", Reference{Type: BlockRef, ID: otherID, PageID: id, Name: "tech-standup"}, false},
+		{"other anchor", "https://www.notion.so/Tech-standup-3aa9e2a7e3d24fe1b92f7fef71e05760#top", Reference{Type: PageRef, ID: id, Name: "tech-standup"}, false},
+		{"no ID", "https://www.notion.so/samkingston/Tech-standup", Reference{}, true},
+		{"bad peek", "https://www.notion.so/Tasks-3aa9e2a7e3d24fe1b92f7fef71e05760?p=nope", Reference{}, true},
+		{"not a URL", "meeting notes", Reference{}, true},
+		{"other scheme", "ftp://www.notion.so/3aa9e2a7e3d24fe1b92f7fef71e05760", Reference{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNotionHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"www.notion.so", true},
+		{"notion.so", true},
+		{"www.notion.com", true},
+		{"team.notion.site", true},
+		{"notnotion.so", false},
+		{"notion.so.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := IsNotionHost(tt.host); got != tt.want {
+				t.Errorf("IsNotionHost(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+	"github.com/s-kngstn/notionsync/pkg/logging"
+)
+
+// Doer sends HTTP requests. It is the same as api.HttpClientInterface, so a Recorder can wrap the
+// client the API client uses, and a Replayer can stand in for it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Fixture is a list of recorded exchanges with Notion, as it is kept in a fixture file.
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it got.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Its headers are not recorded, so neither is the token.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body
+}
+
+// Response is a recorded response, with only the headers that change how it is handled.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body
+}
+
+// Body is the body of a request or response. JSON bodies are kept as JSON, so that fixture files
+// can be read and edited, and anything else as text.
+type Body struct {
+	JSON json.RawMessage `json:"body,omitempty"`
+	Text string          `json:"body_text,omitempty"`
+}
+
+func newBody(data []byte) Body {
+	if len(data) == 0 {
+		return Body{}
+	}
+	if json.Valid(data) {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err == nil {
+			return Body{JSON: compact.Bytes()}
+		}
+	}
+	return Body{Text: string(data)}
+}
+
+func (b Body) bytes() []byte {
+	if len(b.JSON) > 0 {
+		return b.JSON
+	}
+	return []byte(b.Text)
+}
+
+// recordedHeaders are the response headers worth keeping. Others, such as cookies, are dropped.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// signedURLParams matches the signature of the signed URLs Notion gives for uploaded files, which
+// grant access to the file to anyone who has them.
+var signedURLParams = regexp.MustCompile(`(X-Amz-(?:Credential|Security-Token|Signature)=)[^&"\s\\]+`)
+
+// sanitize removes tokens and file signatures from a recorded URL or body.
+func sanitize(s string) string {
+	return signedURLParams.ReplaceAllString(logging.Redact(s), "${1}"+logging.Redacted)
+}
+
+// Load reads a fixture file.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture: %w", err)
+	}
+	fixture := &Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("error parsing fixture %s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// Save writes the fixture to a file.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding fixture: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing fixture: %w", err)
+	}
+	return nil
+}
+
+// Recorder sends requests with Client and records every exchange, sanitized as it is recorded so that
+// no token is ever kept. Requests that fail without a response are not recorded. It is safe for concurrent use.
+type Recorder struct {
+	Client Doer
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder wraps client so that its exchanges are recorded.
+func NewRecorder(client Doer) *Recorder {
+	return &Recorder{Client: client}
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		requestBody, err = io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	// The body is read here, so the caller gets a copy of it
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    sanitize(req.URL.String()),
+			Body:   newBody([]byte(sanitize(string(requestBody)))),
+		},
+		Response: Response{
+			Status: resp.StatusCode,
+			Body:   newBody([]byte(sanitize(string(responseBody)))),
+		},
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if interaction.Response.Header == nil {
+				interaction.Response.Header = make(http.Header)
+			}
+			interaction.Response.Header.Set(name, value)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Interactions = append(r.fixture.Interactions, interaction)
+	return resp, nil
+}
+
+// Fixture returns what has been recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{Interactions: append([]Interaction(nil), r.fixture.Interactions...)}
+}
+
+// Save writes what has been recorded so far to a fixture file.
+func (r *Recorder) Save(path string) error {
+	return r.Fixture().Save(path)
+}
+
+// Replayer answers requests with the responses recorded in a fixture, without sending anything.
+// A request gets the first response recorded for the same method, URL and body that has not been
+// replayed yet, so that a request that was retried gets each of its responses in turn. Once they
+// have all been replayed, it gets the last one again. It is safe for concurrent use.
+type Replayer struct {
+	mu       sync.Mutex
+	fixture  *Fixture
+	replayed []bool
+}
+
+var _ Doer = (*Replayer)(nil)
+
+// NewReplayer creates a Replayer for the interactions in a fixture.
+func NewReplayer(fixture *Fixture) *Replayer {
+	return &Replayer{fixture: fixture, replayed: make([]bool, len(fixture.Interactions))}
+}
+
+// LoadReplayer creates a Replayer for a fixture file.
+func LoadReplayer(path string) (*Replayer, error) {
+	fixture, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(fixture), nil
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	var body Body
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = newBody([]byte(sanitize(string(data))))
+	}
+	// Requests were sanitized as they were recorded, so they are compared sanitized
+	url := sanitize(req.URL.String())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, interaction := range r.fixture.Interactions {
+		if !interaction.Request.matches(req.Method, url, body) {
+			continue
+		}
+		match = i
+		if !r.replayed[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, url)
+	}
+	r.replayed[match] = true
+
+	recorded := r.fixture.Interactions[match].Response
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode: recorded.Status,
+		Header:     recorded.Header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader(recorded.bytes())),
+		Request:    req,
+	}, nil
+}
+
+// Unused returns the interactions that have not been replayed, to check that a test made every request it was expected to.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range r.fixture.Interactions {
+		if !r.replayed[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// matches compares a recorded request with a sanitized one. Fixture files are saved indented, so the
+// recorded body is compacted again first.
+func (r Request) matches(method, url string, body Body) bool {
+	return r.Method == method && r.URL == url && bytes.Equal(newBody(r.bytes()).bytes(), body.bytes())
+}
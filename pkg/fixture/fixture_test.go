@@ -0,0 +1,128 @@
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const token = "secret_abcdefghijklmnopqrstuvwxyz0123456789"
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		switch r.URL.Path {
+		case "/v1/blocks/page/children":
+			fmt.Fprint(w, `{"results": [{"type": "image", "image": {"type": "file", "file": {"url": "https://files.example.com/a.png?X-Amz-Credential=AKIA123&X-Amz-Signature=deadbeef&x=1"}}}]}`)
+		case "/v1/search":
+			body, _ := io.ReadAll(r.Body)
+			// Echo the request, token and all, to check that it is stripped from the response too
+			fmt.Fprintf(w, `{"results": [], "echo": %q, "auth": %q}`, body, r.Header.Get("Authorization"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"object": "error", "status": 404, "code": "object_not_found"}`)
+		}
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(&http.Client{})
+	send := func(client Doer, method, path, body string) (int, string) {
+		t.Helper()
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, reader)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Did not expect an error but got one: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	requests := []struct{ method, path, body string }{
+		{"GET", "/v1/blocks/page/children", ""},
+		{"POST", "/v1/search", `{"query": "` + token + `"}`},
+		{"GET", "/v1/blocks/missing/children", ""},
+	}
+	var live []string
+	for _, r := range requests {
+		status, body := send(recorder, r.method, r.path, r.body)
+		live = append(live, fmt.Sprint(status, " ", body))
+	}
+	if !strings.Contains(live[0], "X-Amz-Signature=deadbeef") {
+		t.Errorf("Expected the caller to get the response as it was, got %q", live[0])
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	for _, secret := range []string{token, "deadbeef", "AKIA123", "session=abc"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("Expected %q to be stripped from the fixture:\n%s", secret, data)
+		}
+	}
+
+	replayer, err := LoadReplayer(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	for i, r := range []struct{ method, path, body string }{
+		{"GET", "/v1/blocks/page/children", ""},
+		{"GET", "/v1/blocks/missing/children", ""},
+	} {
+		status, body := send(replayer, r.method, r.path, r.body)
+		if i == 0 && (status != http.StatusOK || !strings.Contains(body, `"results"`)) {
+			t.Errorf("Expected the recorded page, got %d %s", status, body)
+		}
+		if i == 1 && status != http.StatusNotFound {
+			t.Errorf("Expected the recorded 404, got %d %s", status, body)
+		}
+	}
+	if unused := replayer.Unused(); len(unused) != 1 || unused[0].Request.URL != server.URL+"/v1/search" {
+		t.Errorf("Expected the search to be unused, got %+v", unused)
+	}
+	// The token was redacted from the recorded search, and is from the search being replayed too
+	if status, body := send(replayer, requests[1].method, requests[1].path, requests[1].body); status != http.StatusOK || !strings.Contains(body, `"results"`) {
+		t.Errorf("Expected the recorded search, got %d %s", status, body)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/v1/pages/other", nil)
+	if _, err := replayer.Do(req); err == nil {
+		t.Errorf("Expected an error for a request that was not recorded")
+	}
+}
+
+func TestReplayRetries(t *testing.T) {
+	url := "https://api.notion.com/v1/blocks/page/children?page_size=100"
+	replayer := NewReplayer(&Fixture{Interactions: []Interaction{
+		{Request: Request{Method: "GET", URL: url}, Response: Response{Status: 429, Header: http.Header{"Retry-After": {"0"}}}},
+		{Request: Request{Method: "GET", URL: url}, Response: Response{Status: 200, Body: Body{JSON: []byte(`{"results":[]}`)}}},
+	}})
+
+	for _, expected := range []int{429, 200, 200} {
+		req, _ := http.NewRequest("GET", url, nil)
+		resp, err := replayer.Do(req)
+		if err != nil {
+			t.Fatalf("Did not expect an error but got one: %v", err)
+		}
+		if resp.StatusCode != expected {
+			t.Errorf("Expected status %d, got %d", expected, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in log output.
+const Redacted = "[REDACTED]"
+
+// tokenPattern matches Notion integration tokens, old and new, and the value of an Authorization header.
+var tokenPattern = regexp.MustCompile(`\b(secret_|ntn_)[A-Za-z0-9]{20,}|(?i:bearer)\s+[A-Za-z0-9._~+/=-]{16,}`)
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"bearer_token":  true,
+	"access_token":  true,
+	"authorization": true,
+	"client_secret": true,
+	"passphrase":    true,
+	"password":      true,
+}
+
+// minSecretLength keeps a short value, which would match all over the place, from being registered as a secret.
+const minSecretLength = 8
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret registers a value, such as a token that does not look like a Notion token, to be redacted wherever it appears.
+func AddSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact replaces the secrets registered with AddSecret, and anything that looks like a token, in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	secretsMu.RUnlock()
+	return tokenPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if fields := strings.Fields(match); len(fields) == 2 {
+			// Keep the scheme of an Authorization header
+			return fields[0] + " " + Redacted
+		}
+		return Redacted
+	})
+}
+
+// New creates a logger that writes records at level and above to w, as JSON or as text, with secrets redacted.
+func New(w io.Writer, level slog.Leveler, json bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if json {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(NewRedactingHandler(handler))
+}
+
+// redactingHandler redacts secrets from the message and attributes of every record before handing it on.
+type redactingHandler struct {
+	inner slog.Handler
+}
+
+// NewRedactingHandler wraps a handler so that nothing it writes contains a secret.
+func NewRedactingHandler(inner slog.Handler) slog.Handler {
+	return redactingHandler{inner: inner}
+}
+
+func (h redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		clean.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.inner.Handle(ctx, clean)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		clean[i] = redactAttr(attr)
+	}
+	return redactingHandler{inner: h.inner.WithAttrs(clean)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{inner: h.inner.WithGroup(name)}
+}
+
+// redactAttr redacts an attribute. Values other than strings, numbers, times and groups are
+// logged as their string form, so that a secret inside an error or a struct cannot slip through.
+func redactAttr(attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		clean := make([]any, len(group))
+		for i, member := range group {
+			clean[i] = redactAttr(member)
+		}
+		return slog.Group(attr.Key, clean...)
+	case slog.KindAny:
+		return slog.String(attr.Key, Redact(fmt.Sprint(value.Any())))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
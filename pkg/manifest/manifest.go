@@ -0,0 +1,135 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+)
+
+// FileName is the name of the manifest file, kept in the output directory.
+const FileName = ".notionsync.json"
+
+// Manifest records what a pull wrote, so that later runs can tell what changed since.
+type Manifest struct {
+	// Pages maps the compact ID of each page to what was written for it.
+	Pages map[string]Entry `json:"pages"`
+}
+
+// Entry is a page written by a pull.
+type Entry struct {
+	// Path is the file the page was written to, relative to the output directory.
+	Path string `json:"path"`
+	// Hash is the SHA-256 of the file as it was written.
+	Hash     string    `json:"hash"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// State is how a page compares to the last time it was pulled.
+type State string
+
+const (
+	Unchanged      State = "unchanged"
+	LocalChanges   State = "modified"
+	RemoteChanges  State = "changed in Notion"
+	BothChanged    State = "conflict"
+	DeletedLocally State = "deleted"
+)
+
+// Load reads the manifest from an output directory. A directory without one gives an empty manifest.
+func Load(dir string) (*Manifest, error) {
+	m := &Manifest{Pages: make(map[string]Entry)}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %w", filepath.Join(dir, FileName), err)
+	}
+	if m.Pages == nil {
+		m.Pages = make(map[string]Entry)
+	}
+	return m, nil
+}
+
+// Save writes the manifest into an output directory.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, FileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
+
+// Record notes that a page was written to path, which is inside dir, taking the hash of the file as it is now.
+func (m *Manifest) Record(dir, pageID, path string, syncedAt time.Time) error {
+	hash, err := HashFile(path)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+	m.Pages[api.NormalizeID(pageID)] = Entry{Path: filepath.ToSlash(path), Hash: hash, SyncedAt: syncedAt.UTC()}
+	return nil
+}
+
+// IDs returns the IDs of the pages in the manifest, ordered by the path they were written to.
+func (m *Manifest) IDs() []string {
+	ids := make([]string, 0, len(m.Pages))
+	for id := range m.Pages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return m.Pages[ids[i]].Path < m.Pages[ids[j]].Path
+	})
+	return ids
+}
+
+// Compare works out the state of a page, from its file in dir and the time it was last edited in Notion.
+// Notion only keeps edit times to the minute, so an edit made within a minute after a pull can go unnoticed.
+func (e Entry) Compare(dir string, lastEdited time.Time) (State, error) {
+	hash, err := HashFile(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return DeletedLocally, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	local := hash != e.Hash
+	remote := lastEdited.After(e.SyncedAt)
+	switch {
+	case local && remote:
+		return BothChanged, nil
+	case local:
+		return LocalChanges, nil
+	case remote:
+		return RemoteChanges, nil
+	}
+	return Unchanged, nil
+}
+
+// HashFile returns the hex encoded SHA-256 of a file's content.
+func HashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
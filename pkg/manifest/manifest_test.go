@@ -0,0 +1,85 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	empty, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(empty.Pages) != 0 {
+		t.Fatalf("Expected an empty manifest, got %v", empty.Pages)
+	}
+
+	path := filepath.Join(dir, "notes", "page.md")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("# Page\n"), 0644)
+
+	syncedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := empty.Record(dir, "0123abcd-0000-0000-0000-000000000000", path, syncedAt); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if err := empty.Save(dir); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	entry, ok := loaded.Pages["0123abcd000000000000000000000000"]
+	if !ok {
+		t.Fatalf("Expected the page to be recorded by its compact ID, got %v", loaded.Pages)
+	}
+	if entry.Path != "notes/page.md" || !entry.SyncedAt.Equal(syncedAt) || entry.Hash == "" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
+
+func TestEntryCompare(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+	os.WriteFile(path, []byte("# Page\n"), 0644)
+
+	syncedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manifest{Pages: make(map[string]Entry)}
+	m.Record(dir, "page", path, syncedAt)
+	entry := m.Pages["page"]
+
+	before, after := syncedAt.Add(-time.Minute), syncedAt.Add(time.Minute)
+	tests := []struct {
+		name       string
+		content    string
+		deleted    bool
+		lastEdited time.Time
+		expected   State
+	}{
+		{name: "unchanged", content: "# Page\n", lastEdited: before, expected: Unchanged},
+		{name: "edited locally", content: "# Page\nmore\n", lastEdited: before, expected: LocalChanges},
+		{name: "edited in Notion", content: "# Page\n", lastEdited: after, expected: RemoteChanges},
+		{name: "edited in both", content: "# Page\nmore\n", lastEdited: after, expected: BothChanged},
+		{name: "deleted locally", deleted: true, lastEdited: before, expected: DeletedLocally},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(path)
+			if !tt.deleted {
+				os.WriteFile(path, []byte(tt.content), 0644)
+			}
+			state, err := entry.Compare(dir, tt.lastEdited)
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			if state != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, state)
+			}
+		})
+	}
+}
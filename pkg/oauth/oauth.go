@@ -0,0 +1,240 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// Notion's OAuth endpoints.
+const (
+	AuthorizeURL = "https://api.notion.com/v1/oauth/authorize"
+	TokenURL     = "https://api.notion.com/v1/oauth/token"
+)
+
+// DefaultRedirectURL is where the browser is sent back to once access is granted. It has to be
+// one of the redirect URIs of the integration, exactly as written there.
+const DefaultRedirectURL = "http://localhost:8765/callback"
+
+// Config describes a public integration and how to sign in with it.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is a loopback URL that the flow listens on for the browser to come back to.
+	// Port 0 listens on any free port, which is only useful when the server accepts any redirect URI.
+	RedirectURL string
+	// AuthorizeURL and TokenURL default to Notion's endpoints.
+	AuthorizeURL string
+	TokenURL     string
+	HTTPClient   *http.Client
+	// OpenBrowser is called with the URL the user signs in at. It defaults to OpenBrowser.
+	OpenBrowser func(authURL string) error
+}
+
+// Token is the result of a sign-in: an access token along with the workspace it gives access to.
+type Token struct {
+	AccessToken   string `json:"access_token"`
+	TokenType     string `json:"token_type"`
+	BotID         string `json:"bot_id"`
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceName string `json:"workspace_name"`
+	WorkspaceIcon string `json:"workspace_icon"`
+}
+
+// errorResponse is the body of a failed token request.
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Code             string `json:"code"`
+	Message          string `json:"message"`
+}
+
+// Login runs the authorization code flow. It listens on the redirect URL, sends the user to the
+// authorize URL to pick a workspace and the pages to share, waits for the browser to come back
+// with a code, and exchanges the code for an access token. It gives up when ctx is done.
+func (c Config) Login(ctx context.Context) (*Token, error) {
+	redirect, err := url.Parse(c.redirectURL())
+	if err != nil || redirect.Scheme != "http" || redirect.Host == "" {
+		return nil, fmt.Errorf("invalid redirect URL %q, expected a loopback URL like %s", c.RedirectURL, DefaultRedirectURL)
+	}
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("error listening for the redirect: %w", err)
+	}
+	if redirect.Port() == "0" {
+		port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+		redirect.Host = net.JoinHostPort(redirect.Hostname(), port)
+	}
+	c.RedirectURL = redirect.String()
+
+	state, err := randomState()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	mux := http.NewServeMux()
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var res result
+		switch {
+		case query.Get("state") != state:
+			res.err = errors.New("the redirect did not come from this sign-in, its state does not match")
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("access was not granted: %s", query.Get("error"))
+		case query.Get("code") == "":
+			res.err = errors.New("the redirect has no authorization code")
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, "Sign-in failed: "+res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Signed in to Notion. You can close this window and return to notionsync.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	openBrowser := c.OpenBrowser
+	if openBrowser == nil {
+		openBrowser = OpenBrowser
+	}
+	if err := openBrowser(c.AuthCodeURL(state)); err != nil {
+		return nil, fmt.Errorf("error opening browser: %w", err)
+	}
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return c.Exchange(ctx, res.code)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting for the sign-in: %w", ctx.Err())
+	}
+}
+
+// AuthCodeURL returns the URL where the user grants the integration access.
+func (c Config) AuthCodeURL(state string) string {
+	query := url.Values{
+		"client_id":     {c.ClientID},
+		"response_type": {"code"},
+		"owner":         {"user"},
+		"redirect_uri":  {c.redirectURL()},
+		"state":         {state},
+	}
+	authorizeURL := c.AuthorizeURL
+	if authorizeURL == "" {
+		authorizeURL = AuthorizeURL
+	}
+	return authorizeURL + "?" + query.Encode()
+}
+
+// Exchange trades an authorization code for an access token.
+func (c Config) Exchange(ctx context.Context, code string) (*Token, error) {
+	body, err := json.Marshal(map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": c.redirectURL(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = TokenURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Notion-Version", "2022-06-28")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		var apiError errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiError); err != nil {
+			return nil, fmt.Errorf("error parsing token error response: %w", err)
+		}
+		if apiError.Error == "" {
+			return nil, fmt.Errorf("API Error: %s - %s", apiError.Code, apiError.Message)
+		}
+		return nil, fmt.Errorf("API Error: %s - %s", apiError.Error, apiError.ErrorDescription)
+	}
+
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("the token response has no access token")
+	}
+	return &token, nil
+}
+
+func (c Config) redirectURL() string {
+	if c.RedirectURL == "" {
+		return DefaultRedirectURL
+	}
+	return c.RedirectURL
+}
+
+// randomState returns the value that ties the redirect to this sign-in, so that a redirect started elsewhere is refused.
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OpenBrowser opens a URL in the user's default browser.
+func OpenBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,132 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer is a stand-in for Notion's OAuth endpoints. Its authorize endpoint grants access
+// straight away, redirecting back with a code, and its token endpoint accepts that code once.
+func fakeAuthServer(t *testing.T, deny bool) *httptest.Server {
+	t.Helper()
+	const code = "test-code"
+	used := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != "client" || query.Get("response_type") != "code" || query.Get("owner") != "user" {
+			http.Error(w, "bad authorize request", http.StatusBadRequest)
+			return
+		}
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		values := url.Values{"state": {query.Get("state")}}
+		if deny {
+			values.Set("error", "access_denied")
+		} else {
+			values.Set("code", code)
+		}
+		redirect.RawQuery = values.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if !ok || clientID != "client" || secret != "secret" || body["code"] != code || used || body["grant_type"] != "authorization_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"bad code or client"}`))
+			return
+		}
+		used = true
+		w.Write([]byte(`{"access_token":"secret_oauth","token_type":"bearer","bot_id":"bot","workspace_id":"ws-1","workspace_name":"Acme"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testConfig(server *httptest.Server) Config {
+	return Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://127.0.0.1:0/callback",
+		AuthorizeURL: server.URL + "/authorize",
+		TokenURL:     server.URL + "/token",
+		// The browser is a client that follows the redirect back to the loopback server
+		OpenBrowser: func(authURL string) error {
+			go http.Get(authURL)
+			return nil
+		},
+	}
+}
+
+func TestLogin(t *testing.T) {
+	server := fakeAuthServer(t, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, err := testConfig(server).Login(ctx)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if token.AccessToken != "secret_oauth" || token.WorkspaceID != "ws-1" || token.WorkspaceName != "Acme" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+}
+
+func TestLoginDenied(t *testing.T) {
+	server := fakeAuthServer(t, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := testConfig(server).Login(ctx)
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("Expected access to be denied, got %v", err)
+	}
+}
+
+func TestLoginRejectsWrongState(t *testing.T) {
+	server := fakeAuthServer(t, false)
+	config := testConfig(server)
+	config.OpenBrowser = func(authURL string) error {
+		parsed, _ := url.Parse(authURL)
+		redirect := parsed.Query().Get("redirect_uri")
+		go http.Get(redirect + "?code=test-code&state=forged")
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := config.Login(ctx); err == nil || !strings.Contains(err.Error(), "state") {
+		t.Errorf("Expected a state mismatch, got %v", err)
+	}
+}
+
+func TestLoginTimesOut(t *testing.T) {
+	server := fakeAuthServer(t, false)
+	config := testConfig(server)
+	config.OpenBrowser = func(string) error { return nil }
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := config.Login(ctx); err == nil {
+		t.Errorf("Expected an error when the browser never comes back")
+	}
+}
+
+func TestExchangeError(t *testing.T) {
+	server := fakeAuthServer(t, false)
+	config := testConfig(server)
+
+	_, err := config.Exchange(context.Background(), "wrong-code")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffCells bounds the table used to match lines, so that a huge rewrite does not use up memory.
+// Files that differ by more than that are shown as every old line removed and every new line added.
+const maxDiffCells = 1 << 22
+
+type lineOp struct {
+	kind byte // ' ' for a kept line, '-' for a removed line, '+' for an added line
+	line string
+}
+
+// Diff compares two versions of a file line by line, and returns the changes as the hunks of a
+// unified diff: a header line followed by the lines kept, removed and added, each prefixed by
+// ' ', '-' or '+'. It returns nil when the content is the same.
+func Diff(old, new string) []string {
+	ops := diffLines(splitLines(old), splitLines(new))
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// oldLine and newLine are the 1-based line numbers before each op
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var hunks []string
+	for start := 0; start < len(changes); {
+		// Changes closer together than twice the context share a hunk
+		end := start
+		for end+1 < len(changes) && changes[end+1]-changes[end] <= 2*diffContext {
+			end++
+		}
+		from := max(changes[start]-diffContext, 0)
+		to := min(changes[end]+diffContext+1, len(ops))
+
+		oldCount := oldLine[to] - oldLine[from]
+		newCount := newLine[to] - newLine[from]
+		hunks = append(hunks, fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldLine[from], oldCount), hunkRange(newLine[from], newCount)))
+		for _, op := range ops[from:to] {
+			hunks = append(hunks, string(op.kind)+op.line)
+		}
+		start = end + 1
+	}
+	return hunks
+}
+
+// hunkRange formats the lines a hunk covers. An empty range refers to the line before it, as in diff.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// diffLines matches the lines of a and b with a longest common subsequence, after setting aside
+// the lines they start and end with in common.
+func diffLines(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	ops = append(ops, matchLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	return ops
+}
+
+func matchLines(a, b []string) []lineOp {
+	var ops []lineOp
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, lineOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, lineOp{'+', line})
+		}
+		return ops
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			ops = append(ops, lineOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, lineOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, lineOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, lineOp{'+', b[j]})
+	}
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
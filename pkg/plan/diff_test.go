@@ -0,0 +1,63 @@
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []string
+	}{
+		{
+			name: "unchanged",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+		},
+		{
+			name:     "changed line",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nc\n",
+			expected: []string{"@@ -1,3 +1,3 @@", " a", "-b", "+B", " c"},
+		},
+		{
+			name:     "added to empty file",
+			old:      "",
+			new:      "a\n",
+			expected: []string{"@@ -0,0 +1,1 @@", "+a"},
+		},
+		{
+			name:     "removed at the end",
+			old:      "a\nb\n",
+			new:      "a\n",
+			expected: []string{"@@ -1,2 +1,1 @@", " a", "-b"},
+		},
+		{
+			name: "context around each change",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\neleven\n12\n",
+			expected: []string{
+				"@@ -1,5 +1,5 @@", " 1", "-2", "+two", " 3", " 4", " 5",
+				"@@ -8,5 +8,5 @@", " 8", " 9", " 10", "-11", "+eleven", " 12",
+			},
+		},
+		{
+			name:     "nearby changes share a hunk",
+			old:      "a\nb\nc\nd\ne\n",
+			new:      "A\nb\nc\nd\nE\n",
+			expected: []string{"@@ -1,5 +1,5 @@", "-a", "+A", " b", " c", " d", "-e", "+E"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.old, tt.new); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
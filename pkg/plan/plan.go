@@ -0,0 +1,156 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action is what a run would do with a file.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	// Skip is a file whose content would not change.
+	Skip Action = "skip"
+	// Prune is a file from an earlier run that this run would delete.
+	Prune Action = "prune"
+)
+
+// Change is what a run would do with a single file.
+type Change struct {
+	Action Action `json:"action"`
+	// Path is relative to the output directory.
+	Path string `json:"path"`
+	// PageID is the compact ID of the page in the file, empty for the other files a flavour writes.
+	PageID string `json:"page_id,omitempty"`
+	// Diff holds the hunks of the line diff between the file on disk and its new content, for updates.
+	Diff []string `json:"diff,omitempty"`
+}
+
+// Plan is everything a run would do to an output directory.
+type Plan struct {
+	Dir     string   `json:"dir"`
+	Changes []Change `json:"changes"`
+}
+
+// Recorder keeps the files of a run in memory instead of writing them. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{files: make(map[string][]byte)}
+}
+
+// WriteFile records the content a file would be written with.
+func (r *Recorder) WriteFile(path string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = bytes.Clone(data)
+	return nil
+}
+
+// Files returns the content of every file recorded, keyed by path.
+func (r *Recorder) Files() map[string][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make(map[string][]byte, len(r.files))
+	for path, data := range r.files {
+		files[path] = data
+	}
+	return files
+}
+
+// Build works out a plan by comparing the files a run would write with the files in dir. pages maps
+// the compact ID of each page to the file it would be written to, and pruned the compact ID of each
+// page to the file that would be deleted for it. Paths are the ones the run uses, inside dir.
+func Build(dir string, files map[string][]byte, pages, pruned map[string]string) (*Plan, error) {
+	pageIDs := make(map[string]string, len(pages))
+	for id, path := range pages {
+		pageIDs[path] = id
+	}
+
+	p := &Plan{Dir: dir, Changes: []Change{}}
+	for path, data := range files {
+		change := Change{Path: relativePath(dir, path), PageID: pageIDs[path]}
+		existing, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			change.Action = Create
+		case err != nil:
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		case bytes.Equal(existing, data):
+			change.Action = Skip
+		default:
+			change.Action = Update
+			change.Diff = Diff(string(existing), string(data))
+		}
+		p.Changes = append(p.Changes, change)
+	}
+	for id, path := range pruned {
+		p.Changes = append(p.Changes, Change{Action: Prune, Path: relativePath(dir, path), PageID: id})
+	}
+
+	sort.Slice(p.Changes, func(i, j int) bool {
+		return p.Changes[i].Path < p.Changes[j].Path
+	})
+	return p, nil
+}
+
+func relativePath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// Count returns the number of files the plan does action to.
+func (p *Plan) Count(action Action) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Print writes the plan for people to read: a line for each file, followed by the diff of each update, and a summary.
+func (p *Plan) Print(w io.Writer) error {
+	var sb strings.Builder
+	for _, change := range p.Changes {
+		fmt.Fprintf(&sb, "%-7s %s\n", change.Action, change.Path)
+		for _, line := range change.Diff {
+			sb.WriteString("        " + line + "\n")
+		}
+	}
+	fmt.Fprintf(&sb, "Plan for %s: %d to create, %d to update, %d unchanged, %d to prune\n",
+		p.Dir, p.Count(Create), p.Count(Update), p.Count(Skip), p.Count(Prune))
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes the plan as JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "same.md"), []byte("# Same\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "edited.md"), []byte("# Edited\n\nold\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "gone.md"), []byte("# Gone\n"), 0644)
+
+	recorder := NewRecorder()
+	recorder.WriteFile(filepath.Join(dir, "same.md"), []byte("# Same\n"))
+	recorder.WriteFile(filepath.Join(dir, "edited.md"), []byte("# Edited\n\nnew\n"))
+	recorder.WriteFile(filepath.Join(dir, "notes", "new.md"), []byte("# New\n"))
+	pages := map[string]string{
+		"same":   filepath.Join(dir, "same.md"),
+		"edited": filepath.Join(dir, "edited.md"),
+		"new":    filepath.Join(dir, "notes", "new.md"),
+	}
+	pruned := map[string]string{"gone": filepath.Join(dir, "gone.md")}
+
+	p, err := Build(dir, recorder.Files(), pages, pruned)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	expected := []Change{
+		{Action: Update, Path: "edited.md", PageID: "edited", Diff: []string{"@@ -1,3 +1,3 @@", " # Edited", " ", "-old", "+new"}},
+		{Action: Prune, Path: "gone.md", PageID: "gone"},
+		{Action: Create, Path: "notes/new.md", PageID: "new"},
+		{Action: Skip, Path: "same.md", PageID: "same"},
+	}
+	if !reflect.DeepEqual(p.Changes, expected) {
+		t.Errorf("Expected changes %+v, got %+v", expected, p.Changes)
+	}
+
+	var out bytes.Buffer
+	if err := p.Print(&out); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	for _, want := range []string{"update  edited.md\n", "        -old\n", "create  notes/new.md\n", "1 to create, 1 to update, 1 unchanged, 1 to prune\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the plan to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := p.WriteJSON(&out); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	var decoded Plan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to parse the JSON plan: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Changes, expected) {
+		t.Errorf("Expected the JSON plan to round trip, got %+v", decoded.Changes)
+	}
+
+	// Nothing was written
+	if _, err := os.Stat(filepath.Join(dir, "notes")); !os.IsNotExist(err) {
+		t.Errorf("Expected the new page not to be written, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Kind is what happened in an Event.
+type Kind int
+
+const (
+	// Discovered is a page queued to be exported.
+	Discovered Kind = iota
+	// Fetched is a page whose blocks were fetched.
+	Fetched
+	// Written is a page written to its file.
+	Written
+	// Failed is a page that could not be exported.
+	Failed
+	// Request is a request sent to Notion.
+	Request
+	// RateLimited is a request held back by a 429 response, for Event.Wait.
+	RateLimited
+)
+
+// Event is something that happened during an export.
+type Event struct {
+	Kind   Kind
+	PageID string
+	// Wait is how long a rate limited request waits before it is retried.
+	Wait time.Duration
+}
+
+// Listener receives the events of an export. A nil Listener ignores them, so that
+// the code emitting events does not have to check whether anyone is listening.
+type Listener func(Event)
+
+// Emit hands an event to the listener.
+func (l Listener) Emit(event Event) {
+	if l != nil {
+		l(event)
+	}
+}
+
+// Counts are the number of events of each kind seen so far.
+type Counts struct {
+	Discovered, Fetched, Written, Failed int
+	Requests, RateLimited                int
+	// Waited is the total time requests were held back by rate limiting.
+	Waited time.Duration
+}
+
+// add counts an event.
+func (c *Counts) add(event Event) {
+	switch event.Kind {
+	case Discovered:
+		c.Discovered++
+	case Fetched:
+		c.Fetched++
+	case Written:
+		c.Written++
+	case Failed:
+		c.Failed++
+	case Request:
+		c.Requests++
+	case RateLimited:
+		c.RateLimited++
+		c.Waited += event.Wait
+	}
+}
+
+// ETA estimates how long the pages discovered so far but not yet finished will take, from the time
+// the finished ones took. As more pages are discovered along the way, it is a lower bound.
+// It returns false until a page has finished.
+func (c Counts) ETA(elapsed time.Duration) (time.Duration, bool) {
+	done := c.Written + c.Failed
+	if done == 0 {
+		return 0, false
+	}
+	remaining := c.Discovered - done
+	if remaining < 0 {
+		remaining = 0
+	}
+	return elapsed * time.Duration(remaining) / time.Duration(done), true
+}
+
+// Line describes the counts on a single line.
+func (c Counts) Line(elapsed time.Duration) string {
+	line := fmt.Sprintf("Pages: %d found, %d fetched, %d written, %d failed | %d requests, %d rate limit waits",
+		c.Discovered, c.Fetched, c.Written, c.Failed, c.Requests, c.RateLimited)
+	if eta, ok := c.ETA(elapsed); ok {
+		line += " | ETA " + eta.Round(time.Second).String()
+	}
+	return line
+}
+
+const (
+	// liveInterval is how often the live line is redrawn.
+	liveInterval = 200 * time.Millisecond
+	// logInterval is how often progress is logged when it cannot be shown live.
+	logInterval = 10 * time.Second
+)
+
+// clearLine moves back to the start of the line on a terminal and clears it.
+const clearLine = "\r\033[K"
+
+// Display counts the events of an export and shows how it is going while it runs: on a single
+// line that is redrawn in place when live, or as a log line every few seconds when not, such
+// as when the output is piped to a file. It is safe for concurrent use.
+type Display struct {
+	w    io.Writer
+	live bool
+
+	mu      sync.Mutex
+	counts  Counts
+	start   time.Time
+	drawn   bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewDisplay creates a Display that draws its line on w when live, which should only be when w is a terminal.
+func NewDisplay(w io.Writer, live bool) *Display {
+	return &Display{w: w, live: live, start: time.Now()}
+}
+
+// Handle counts an event. It is a Listener.
+func (d *Display) Handle(event Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.counts.add(event)
+}
+
+// Counts returns the counts so far.
+func (d *Display) Counts() Counts {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.counts
+}
+
+// Start shows the progress until Stop is called.
+func (d *Display) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return
+	}
+	d.start = time.Now()
+	d.stop, d.stopped = make(chan struct{}), make(chan struct{})
+	interval := logInterval
+	if d.live {
+		interval = liveInterval
+	}
+	go d.run(interval, d.stop, d.stopped)
+}
+
+func (d *Display) run(interval time.Duration, stop, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.show()
+		}
+	}
+}
+
+// Stop stops showing the progress. A live line is drawn a last time and left on its own line.
+// It is safe to call more than once.
+func (d *Display) Stop() {
+	d.mu.Lock()
+	stop, stopped := d.stop, d.stopped
+	d.stop = nil
+	d.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-stopped
+
+	if d.live {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		fmt.Fprint(d.w, clearLine+d.counts.Line(time.Since(d.start))+"\n")
+		d.drawn = false
+	}
+}
+
+// show draws the live line, or logs the counts.
+func (d *Display) show() {
+	d.mu.Lock()
+	counts, elapsed := d.counts, time.Since(d.start)
+	if d.live {
+		fmt.Fprint(d.w, clearLine+counts.Line(elapsed))
+		d.drawn = true
+	}
+	d.mu.Unlock()
+	if d.live {
+		return
+	}
+
+	// The log may go through LogWriter, so it is written without holding the lock
+	attrs := []any{
+		"discovered", counts.Discovered,
+		"fetched", counts.Fetched,
+		"written", counts.Written,
+		"failed", counts.Failed,
+		"requests", counts.Requests,
+		"rate_limited", counts.RateLimited,
+		"waited", counts.Waited,
+	}
+	if eta, ok := counts.ETA(elapsed); ok {
+		attrs = append(attrs, "eta", eta.Round(time.Second))
+	}
+	slog.Info("progress", attrs...)
+}
+
+// LogWriter wraps the writer the log goes to, so that log lines written while the live line is
+// shown replace it rather than run into it. The line is drawn again on the next tick.
+func (d *Display) LogWriter(w io.Writer) io.Writer {
+	return logWriter{d: d, w: w}
+}
+
+type logWriter struct {
+	d *Display
+	w io.Writer
+}
+
+func (l logWriter) Write(p []byte) (int, error) {
+	l.d.mu.Lock()
+	defer l.d.mu.Unlock()
+	if l.d.drawn {
+		io.WriteString(l.w, clearLine)
+		l.d.drawn = false
+	}
+	return l.w.Write(p)
+}
@@ -0,0 +1,106 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountsETA(t *testing.T) {
+	tests := []struct {
+		name     string
+		counts   Counts
+		elapsed  time.Duration
+		expected time.Duration
+		ok       bool
+	}{
+		{"nothing finished", Counts{Discovered: 10}, time.Minute, 0, false},
+		{"half finished", Counts{Discovered: 10, Written: 4, Failed: 1}, time.Minute, time.Minute, true},
+		{"all finished", Counts{Discovered: 3, Written: 3}, time.Minute, 0, true},
+		{"a quarter finished", Counts{Discovered: 8, Written: 2}, 10 * time.Second, 30 * time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, ok := tt.counts.ETA(tt.elapsed)
+			if eta != tt.expected || ok != tt.ok {
+				t.Errorf("Expected %v, %v, got %v, %v", tt.expected, tt.ok, eta, ok)
+			}
+		})
+	}
+}
+
+func TestCountsLine(t *testing.T) {
+	counts := Counts{Discovered: 10, Fetched: 6, Written: 5, Requests: 20, RateLimited: 1}
+	expected := "Pages: 10 found, 6 fetched, 5 written, 0 failed | 20 requests, 1 rate limit waits | ETA 30s"
+	if line := counts.Line(30 * time.Second); line != expected {
+		t.Errorf("Expected %q, got %q", expected, line)
+	}
+
+	// There is no estimate until a page has finished
+	if line := (Counts{Discovered: 1}).Line(time.Second); strings.Contains(line, "ETA") {
+		t.Errorf("Did not expect an ETA, got %q", line)
+	}
+}
+
+func TestDisplayHandle(t *testing.T) {
+	display := NewDisplay(nil, false)
+	var listener Listener = display.Handle
+	for _, event := range []Event{
+		{Kind: Discovered, PageID: "a"},
+		{Kind: Discovered, PageID: "b"},
+		{Kind: Request},
+		{Kind: Request},
+		{Kind: RateLimited, Wait: 2 * time.Second},
+		{Kind: Request},
+		{Kind: Fetched, PageID: "a"},
+		{Kind: Written, PageID: "a"},
+		{Kind: Failed, PageID: "b"},
+	} {
+		listener.Emit(event)
+	}
+
+	expected := Counts{Discovered: 2, Fetched: 1, Written: 1, Failed: 1, Requests: 3, RateLimited: 1, Waited: 2 * time.Second}
+	if counts := display.Counts(); counts != expected {
+		t.Errorf("Expected %+v, got %+v", expected, counts)
+	}
+
+	// A nil listener ignores events
+	var none Listener
+	none.Emit(Event{Kind: Written})
+}
+
+func TestDisplayLive(t *testing.T) {
+	var out bytes.Buffer
+	display := NewDisplay(&out, true)
+	display.Handle(Event{Kind: Discovered})
+	display.show()
+	if !strings.HasPrefix(out.String(), clearLine+"Pages: 1 found") {
+		t.Errorf("Expected the progress line, got %q", out.String())
+	}
+
+	// A log line replaces the progress line
+	out.Reset()
+	fmt.Fprint(display.LogWriter(&out), "level=WARN msg=hello\n")
+	if expected := clearLine + "level=WARN msg=hello\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	// Once the line has been replaced, log lines are written as they are
+	out.Reset()
+	fmt.Fprint(display.LogWriter(&out), "level=WARN msg=again\n")
+	if expected := "level=WARN msg=again\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	// Stopping leaves the last line in place
+	display.Start()
+	out.Reset()
+	display.Stop()
+	display.Stop()
+	if !strings.HasSuffix(out.String(), "\n") || strings.Count(out.String(), "Pages:") != 1 {
+		t.Errorf("Expected the final progress line, got %q", out.String())
+	}
+}
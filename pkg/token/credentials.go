@@ -0,0 +1,296 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/s-kngstn/notionsync/pkg/atomicfile"
+	"golang.org/x/crypto/argon2"
+)
+
+// FileName is the name of the credentials file, kept in notionsync/ in the user config directory.
+const FileName = "credentials.json"
+
+const (
+	// PathEnv is the environment variable that moves the credentials file somewhere else.
+	PathEnv = "NOTIONSYNC_CREDENTIALS"
+	// PassphraseEnv is the environment variable the passphrase of an encrypted credentials file is read from.
+	PassphraseEnv = "NOTIONSYNC_PASSPHRASE"
+)
+
+var (
+	// ErrNotFound is returned when there is no saved token for a workspace, or no default workspace.
+	ErrNotFound = errors.New("no saved token")
+	// ErrPassphraseRequired is returned when opening an encrypted credentials file without a passphrase.
+	ErrPassphraseRequired = errors.New("the credentials file is encrypted and needs a passphrase")
+	// ErrWrongPassphrase is returned when an encrypted credentials file cannot be decrypted.
+	ErrWrongPassphrase = errors.New("wrong passphrase, or the credentials file is damaged")
+)
+
+// Credential is a saved token and the workspace it gives access to.
+type Credential struct {
+	// Name is what the workspace is called on the command line, which can differ from its name in Notion.
+	Name          string    `json:"name"`
+	Token         string    `json:"token"`
+	WorkspaceID   string    `json:"workspace_id,omitempty"`
+	WorkspaceName string    `json:"workspace_name,omitempty"`
+	SavedAt       time.Time `json:"saved_at"`
+}
+
+// credentials is what the credentials file holds, encrypted or not.
+type credentials struct {
+	Default    string                `json:"default,omitempty"`
+	Workspaces map[string]Credential `json:"workspaces,omitempty"`
+}
+
+// storedFile is the credentials file as it is written. An encrypted file keeps its
+// credentials in Encrypted, and a plain one in the embedded fields.
+type storedFile struct {
+	Version int `json:"version"`
+	credentials
+	Encrypted *sealed `json:"encrypted,omitempty"`
+}
+
+// sealed is credentials encrypted with AES-256-GCM, under a key derived from the passphrase with Argon2id.
+type sealed struct {
+	KDF        string `json:"kdf"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Argon2id settings for new files, as recommended in RFC 9106 for memory constrained use.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+)
+
+// Store is the credentials file, holding tokens for any number of named workspaces.
+type Store struct {
+	path       string
+	passphrase string
+	creds      credentials
+}
+
+// DefaultPath returns where the credentials file is kept: $NOTIONSYNC_CREDENTIALS if set, otherwise
+// notionsync/credentials.json in the user config directory ($XDG_CONFIG_HOME or ~/.config on Linux).
+func DefaultPath() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding config directory: %w", err)
+	}
+	return filepath.Join(configDir, "notionsync", FileName), nil
+}
+
+// Open reads the credentials file at path. A file that does not exist yet gives an empty store.
+// The passphrase is only needed, and only used, when the file is encrypted.
+func Open(path, passphrase string) (*Store, error) {
+	store := &Store{path: path, creds: credentials{Workspaces: make(map[string]Credential)}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials: %w", err)
+	}
+
+	var file storedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing credentials file %s: %w", path, err)
+	}
+	if file.Encrypted == nil {
+		store.creds = file.credentials
+	} else {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if err := file.Encrypted.open(passphrase, &store.creds); err != nil {
+			return nil, err
+		}
+		store.passphrase = passphrase
+	}
+	if store.creds.Workspaces == nil {
+		store.creds.Workspaces = make(map[string]Credential)
+	}
+	return store, nil
+}
+
+// Path returns the file the store is saved to.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Encrypted reports whether the store is saved encrypted.
+func (s *Store) Encrypted() bool {
+	return s.passphrase != ""
+}
+
+// SetPassphrase sets the passphrase the store is encrypted with when it is next saved. An empty passphrase saves it unencrypted.
+func (s *Store) SetPassphrase(passphrase string) {
+	s.passphrase = passphrase
+}
+
+// Save writes the store to its file, readable by the current user only. The file is replaced
+// in one step, so a failed save leaves the previous credentials in place.
+func (s *Store) Save() error {
+	file := storedFile{Version: 1}
+	if s.passphrase == "" {
+		file.credentials = s.creds
+	} else {
+		sealed, err := seal(s.passphrase, s.creds)
+		if err != nil {
+			return err
+		}
+		file.Encrypted = sealed
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding credentials: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating credentials directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(s.path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("error writing credentials: %w", err)
+	}
+	return nil
+}
+
+// Add saves a credential under its name, replacing any with the same name. The first credential
+// added becomes the default.
+func (s *Store) Add(credential Credential) {
+	if credential.SavedAt.IsZero() {
+		credential.SavedAt = time.Now().UTC()
+	}
+	s.creds.Workspaces[credential.Name] = credential
+	if s.creds.Default == "" {
+		s.creds.Default = credential.Name
+	}
+}
+
+// Remove deletes a credential. When it was the default, the default is cleared, unless only one credential is left.
+func (s *Store) Remove(name string) error {
+	if _, ok := s.creds.Workspaces[name]; !ok {
+		return fmt.Errorf("%w for workspace %q", ErrNotFound, name)
+	}
+	delete(s.creds.Workspaces, name)
+	if s.creds.Default == name {
+		s.creds.Default = ""
+		if len(s.creds.Workspaces) == 1 {
+			for other := range s.creds.Workspaces {
+				s.creds.Default = other
+			}
+		}
+	}
+	return nil
+}
+
+// SetDefault makes a saved workspace the one used when none is named.
+func (s *Store) SetDefault(name string) error {
+	if _, ok := s.creds.Workspaces[name]; !ok {
+		return fmt.Errorf("%w for workspace %q", ErrNotFound, name)
+	}
+	s.creds.Default = name
+	return nil
+}
+
+// Default returns the name of the default workspace, or an empty string when there is none.
+func (s *Store) Default() string {
+	return s.creds.Default
+}
+
+// Get returns the credential saved for a workspace, or for the default workspace when name is empty.
+func (s *Store) Get(name string) (Credential, error) {
+	if name == "" {
+		if s.creds.Default == "" {
+			return Credential{}, fmt.Errorf("%w for a default workspace", ErrNotFound)
+		}
+		name = s.creds.Default
+	}
+	credential, ok := s.creds.Workspaces[name]
+	if !ok {
+		return Credential{}, fmt.Errorf("%w for workspace %q", ErrNotFound, name)
+	}
+	return credential, nil
+}
+
+// List returns every saved credential, sorted by name.
+func (s *Store) List() []Credential {
+	list := make([]Credential, 0, len(s.creds.Workspaces))
+	for _, credential := range s.creds.Workspaces {
+		list = append(list, credential)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func seal(passphrase string, creds credentials) (*sealed, error) {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding credentials: %w", err)
+	}
+	s := &sealed{KDF: "argon2id", Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads, Salt: make([]byte, 16)}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+	gcm, err := s.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	s.Ciphertext = gcm.Seal(nil, s.Nonce, plaintext, nil)
+	return s, nil
+}
+
+func (s *sealed) open(passphrase string, creds *credentials) error {
+	if s.KDF != "argon2id" {
+		return fmt.Errorf("unknown key derivation %q in credentials file", s.KDF)
+	}
+	gcm, err := s.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return ErrWrongPassphrase
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Ciphertext, nil)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, creds); err != nil {
+		return fmt.Errorf("error parsing credentials: %w", err)
+	}
+	return nil
+}
+
+// cipher derives the key from the passphrase with the file's settings.
+func (s *sealed) cipher(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), s.Salt, s.Time, s.Memory, s.Threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
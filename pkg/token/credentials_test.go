@@ -0,0 +1,116 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStoreSaveAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notionsync", FileName)
+	store, err := Open(path, "")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Fatalf("Expected an empty store, got %v", store.List())
+	}
+
+	store.Add(Credential{Name: "work", Token: "secret_work", WorkspaceName: "Acme"})
+	store.Add(Credential{Name: "home", Token: "secret_home"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the credentials file to be written: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, "")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	credential, err := reopened.Get("")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if credential.Name != "work" || credential.Token != "secret_work" || credential.WorkspaceName != "Acme" {
+		t.Errorf("Expected the first workspace added to be the default, got %+v", credential)
+	}
+	if names := []string{reopened.List()[0].Name, reopened.List()[1].Name}; names[0] != "home" || names[1] != "work" {
+		t.Errorf("Expected the workspaces sorted by name, got %v", names)
+	}
+}
+
+func TestStoreEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	store, _ := Open(path, "")
+	store.SetPassphrase("correct horse")
+	store.Add(Credential{Name: "work", Token: "secret_work"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret_work") || strings.Contains(string(data), `"work"`) {
+		t.Errorf("Expected the token and workspace names to be encrypted, got %s", data)
+	}
+
+	if _, err := Open(path, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Expected ErrPassphraseRequired without a passphrase, got %v", err)
+	}
+	if _, err := Open(path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+
+	reopened, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if !reopened.Encrypted() {
+		t.Errorf("Expected the store to stay encrypted")
+	}
+	if credential, err := reopened.Get("work"); err != nil || credential.Token != "secret_work" {
+		t.Errorf("Expected the saved token, got %+v, %v", credential, err)
+	}
+}
+
+func TestStoreRemove(t *testing.T) {
+	store, _ := Open(filepath.Join(t.TempDir(), FileName), "")
+	store.Add(Credential{Name: "a", Token: "1"})
+	store.Add(Credential{Name: "b", Token: "2"})
+	store.Add(Credential{Name: "c", Token: "3"})
+
+	if err := store.Remove("a"); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if _, err := store.Get(""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected no default once the default is removed, got %v", err)
+	}
+	if err := store.Remove("b"); err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if store.Default() != "" {
+		t.Errorf("Expected the default to stay cleared, got %q", store.Default())
+	}
+	if err := store.SetDefault("c"); err != nil || store.Default() != "c" {
+		t.Errorf("Expected c to become the default, got %q, %v", store.Default(), err)
+	}
+	if err := store.Remove("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv(PathEnv, "/tmp/creds.json")
+	if path, err := DefaultPath(); err != nil || path != "/tmp/creds.json" {
+		t.Errorf("Expected the path from %s, got %q, %v", PathEnv, path, err)
+	}
+}
@@ -1,21 +0,0 @@
-package utils
-
-import (
-	"bufio"
-	"os"
-)
-
-func ReadURLs(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var urls []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		urls = append(urls, scanner.Text())
-	}
-	return urls, scanner.Err()
-}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+)
+
+// Target is an entry in a URL list file: a page to sync, with options that apply to it and
+// to every page crawled from it.
+//
+// Each line holds a page URL or a bare page ID, in any form fetch.Resolve accepts, optionally followed by key=value options:
+//
+//	# Team wiki
+//	https://www.notion.so/Wiki-0123456789abcdef0123456789abcdef name=wiki dir=team depth=2
+//	0123456789abcdef0123456789abcdef format=hugo  # release notes
+//
+// Blank lines are skipped, and # starts a comment at the beginning of a line or after a space.
+type Target struct {
+	// Line is the line of the file the entry is on.
+	Line int
+	// Ref is the page, database or block the entry refers to.
+	Ref fetch.Reference
+	// Name replaces the file name taken from the URL.
+	Name string
+	// Dir is a subdirectory of the output directory to write the pages into.
+	Dir string
+	// Depth replaces the -max-depth flag when it is not nil.
+	Depth *int
+	// Flavour replaces the -format flag when it is not empty.
+	Flavour format.Flavour
+}
+
+// ReadTargets reads a URL list file. Every invalid entry is reported, each error starting with
+// the file name and line number.
+func ReadTargets(filePath string) ([]Target, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []Target
+	var errs []error
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		target, err := parseTarget(line, fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", filePath, line, err))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, errors.Join(errs...)
+}
+
+// stripComment removes a comment from a line. A # only starts a comment at the beginning of the
+// line or after whitespace, so that block anchors in URLs are kept.
+func stripComment(line string) string {
+	for i, r := range line {
+		if r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseTarget(line int, fields []string) (Target, error) {
+	target, err := NewTarget(fields[0])
+	if err != nil {
+		return Target{}, err
+	}
+	target.Line = line
+
+	for _, option := range fields[1:] {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return Target{}, fmt.Errorf("option %q should be written as key=value", option)
+		}
+		if err := target.SetOption(key, value); err != nil {
+			return Target{}, err
+		}
+	}
+	return target, nil
+}
+
+// NewTarget creates a Target for a page URL or ID, without any options.
+func NewTarget(page string) (Target, error) {
+	ref, err := fetch.Resolve(page)
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{Ref: ref}, nil
+}
+
+// String formats the target as a line of a URL list file, the page ID followed by its options.
+func (t Target) String() string {
+	fields := []string{t.Ref.ID}
+	if t.Name != "" {
+		fields = append(fields, "name="+t.Name)
+	}
+	if t.Dir != "" {
+		fields = append(fields, "dir="+filepath.ToSlash(t.Dir))
+	}
+	if t.Depth != nil {
+		fields = append(fields, "depth="+strconv.Itoa(*t.Depth))
+	}
+	if t.Flavour != "" {
+		fields = append(fields, "format="+string(t.Flavour))
+	}
+	return strings.Join(fields, " ")
+}
+
+// SetOption validates and sets one of the name, dir, depth or format options.
+func (t *Target) SetOption(key, value string) error {
+	switch key {
+	case "name":
+		if strings.ContainsAny(value, `/\`) {
+			return fmt.Errorf("name %q cannot contain a path separator", value)
+		}
+		t.Name = value
+	case "dir":
+		dir := filepath.Clean(value)
+		if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("dir %q must be inside the output directory", value)
+		}
+		t.Dir = dir
+	case "depth":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < -1 {
+			return fmt.Errorf("depth %q should be a number of levels, or -1 for no limit", value)
+		}
+		t.Depth = &depth
+	case "format":
+		flavour, err := format.ParseFlavour(value)
+		if err != nil {
+			return err
+		}
+		t.Flavour = flavour
+	default:
+		return fmt.Errorf("unknown option %q, expected name, dir, depth or format", key)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/format"
+	"github.com/s-kngstn/notionsync/pkg/fetch"
+)
+
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	return path
+}
+
+func TestReadTargets(t *testing.T) {
+	path := writeTempFile(t, `# Team wiki
+https://www.notion.so/Wiki-0123456789abcdef0123456789abcdef name=wiki dir=team/docs depth=2
+
+0123456789abcdef0123456789abcdef format=hugo  # release notes
+01234567-89ab-cdef-0123-456789abcdef depth=-1
+https://www.notion.so/Page-0123456789abcdef0123456789abcdef#fedcba9876543210fedcba9876543210
+`)
+
+	targets, err := ReadTargets(path)
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+
+	const id = "01234567-89ab-cdef-0123-456789abcdef"
+	two, unlimited := 2, -1
+	expected := []Target{
+		{Line: 2, Ref: fetch.Reference{Type: fetch.PageRef, ID: id, Name: "wiki"}, Name: "wiki", Dir: filepath.Join("team", "docs"), Depth: &two},
+		{Line: 4, Ref: fetch.Reference{Type: fetch.PageRef, ID: id}, Flavour: format.Hugo},
+		{Line: 5, Ref: fetch.Reference{Type: fetch.PageRef, ID: id}, Depth: &unlimited},
+		{Line: 6, Ref: fetch.Reference{Type: fetch.BlockRef, ID: "fedcba98-7654-3210-fedc-ba9876543210", PageID: id, Name: "page"}},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Expected targets %+v, got %+v", expected, targets)
+	}
+}
+
+func TestReadTargetsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{"not a URL", "notes about the wiki", []string{`:1: "notes" is not a Notion URL or page ID`}},
+		{"no page ID", "https://www.notion.so/Page", []string{`:1: no page ID found in URL`}},
+		{"unknown option", "https://www.notion.so/Page-0123456789abcdef0123456789abcdef name=page color=red", []string{`:1: unknown option "color"`}},
+		{"missing value", "\nhttps://www.notion.so/Page-0123456789abcdef0123456789abcdef name", []string{`:2: option "name" should be written as key=value`}},
+		{"bad depth", "https://www.notion.so/Page-0123456789abcdef0123456789abcdef depth=all", []string{`:1: depth "all"`}},
+		{"bad format", "https://www.notion.so/Page-0123456789abcdef0123456789abcdef format=pdf", []string{`:1: unknown format "pdf"`}},
+		{"dir outside output", "https://www.notion.so/Page-0123456789abcdef0123456789abcdef dir=../other", []string{`:1: dir "../other" must be inside the output directory`}},
+		{"name with a path", "https://www.notion.so/Page-0123456789abcdef0123456789abcdef name=a/b", []string{`:1: name "a/b" cannot contain a path separator`}},
+		{"every error reported", "bad\n# fine\nhttps://www.notion.so/Page-0123456789abcdef0123456789abcdef depth=x", []string{":1: ", ":3: "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, tt.content)
+			_, err := ReadTargets(path)
+			if err == nil {
+				t.Fatalf("Expected an error but did not get one")
+			}
+			for _, message := range tt.expected {
+				if !strings.Contains(err.Error(), path+message) {
+					t.Errorf("Expected the error to contain %q, got %q", path+message, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadTargetsMissingFile(t *testing.T) {
+	if _, err := ReadTargets(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("Expected an error but did not get one")
+	}
+}
+
+func TestTargetString(t *testing.T) {
+	const id = "01234567-89ab-cdef-0123-456789abcdef"
+	three := 3
+	tests := []struct {
+		name     string
+		target   Target
+		expected string
+	}{
+		{"no options", Target{Ref: fetch.Reference{Type: fetch.PageRef, ID: id}}, id},
+		{
+			"every option",
+			Target{Ref: fetch.Reference{Type: fetch.PageRef, ID: id}, Name: "wiki", Dir: filepath.Join("team", "docs"), Depth: &three, Flavour: format.Hugo},
+			id + " name=wiki dir=team/docs depth=3 format=hugo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := tt.target.String()
+			if line != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, line)
+			}
+
+			// The line reads back as the same target
+			targets, err := ReadTargets(writeTempFile(t, line+"\n"))
+			if err != nil {
+				t.Fatalf("Did not expect an error but got one: %v", err)
+			}
+			tt.target.Line = 1
+			if !reflect.DeepEqual(targets, []Target{tt.target}) {
+				t.Errorf("Expected %+v, got %+v", tt.target, targets)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/crawl"
+)
+
+// Node is a page or database shared with the integration.
+type Node struct {
+	ID string
+	// Object is page or database.
+	Object   string
+	Title    string
+	Parent   *Node
+	Children []*Node
+}
+
+// Tree is the hierarchy of the pages and databases shared with the integration, built from their
+// parent references. Objects whose parent is not shared, or is the workspace itself, are roots.
+type Tree struct {
+	Roots []*Node
+	nodes map[string]*Node
+}
+
+// ParseObjectType checks the value of the -search-type flag, which can be empty to search for both kinds of object.
+func ParseObjectType(s string) (string, error) {
+	switch objectType := strings.ToLower(s); objectType {
+	case "", "page", "database":
+		return objectType, nil
+	}
+	return "", fmt.Errorf("unknown object type %q, expected page or database", s)
+}
+
+// Discover searches for every page and database shared with the integration that matches the
+// query, and builds their hierarchy. An empty query and objectType find everything.
+func Discover(apiClient api.NotionAPI, query, objectType, bearerToken string) (*Tree, error) {
+	objects, err := api.SearchAll(apiClient, query, objectType, bearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("error searching workspace: %w", err)
+	}
+	return Build(objects), nil
+}
+
+// Build arranges pages and databases into a tree by their parents. Children are sorted by title.
+func Build(objects []api.Page) *Tree {
+	tree := &Tree{nodes: make(map[string]*Node)}
+	for _, object := range objects {
+		id := api.NormalizeID(object.ID)
+		if _, ok := tree.nodes[id]; ok {
+			continue
+		}
+		tree.nodes[id] = &Node{ID: object.ID, Object: object.Object, Title: object.Title()}
+	}
+
+	for _, object := range objects {
+		node := tree.nodes[api.NormalizeID(object.ID)]
+		if node.Parent != nil {
+			continue
+		}
+		var parent *Node
+		if object.Parent != nil {
+			parent = tree.nodes[api.NormalizeID(object.Parent.ID())]
+		}
+		if parent == nil || parent == node {
+			tree.Roots = append(tree.Roots, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortNodes(tree.Roots)
+	for _, node := range tree.nodes {
+		sortNodes(node.Children)
+	}
+	return tree
+}
+
+// Len returns the number of pages and databases in the tree.
+func (t *Tree) Len() int {
+	return len(t.nodes)
+}
+
+// Tasks returns the pages to start crawling from to export the whole tree into dir. Pages whose
+// parent is a page in the tree are left out, as the crawler reaches them as child pages. The
+// rows of a database are written into a directory named after it.
+func (t *Tree) Tasks(dir string) []crawl.Task {
+	var tasks []crawl.Task
+	var walk func(node *Node, dir string)
+	walk = func(node *Node, dir string) {
+		if node.Object == "database" {
+			dir = filepath.Join(dir, node.name())
+		} else if node.Parent == nil || node.Parent.Object == "database" {
+			tasks = append(tasks, crawl.Task{PageID: node.ID, Name: node.name(), Dir: dir})
+		}
+		for _, child := range node.Children {
+			walk(child, dir)
+		}
+	}
+	for _, root := range t.Roots {
+		walk(root, dir)
+	}
+	return tasks
+}
+
+// name is the file or directory name the node is written to.
+func (n *Node) name() string {
+	if n.Title == "" {
+		return api.NormalizeID(n.ID)
+	}
+	return strcase.ToKebab(n.Title)
+}
+
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Title != nodes[j].Title {
+			return nodes[i].Title < nodes[j].Title
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
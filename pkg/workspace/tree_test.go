@@ -0,0 +1,130 @@
+package workspace
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/s-kngstn/notionsync/api"
+	"github.com/s-kngstn/notionsync/pkg/crawl"
+)
+
+type mockNotionAPI struct {
+	api.NotionAPI
+	pages [][]api.Page
+}
+
+// Search returns one page of results per call, using the cursor as the index of the page.
+func (m *mockNotionAPI) Search(request api.SearchRequest, bearerToken string) (*api.SearchResponse, error) {
+	index := 0
+	if request.StartCursor != "" {
+		index = len(request.StartCursor)
+	}
+	resp := &api.SearchResponse{Results: m.pages[index]}
+	if index+1 < len(m.pages) {
+		resp.HasMore = true
+		resp.NextCursor = request.StartCursor + "x"
+	}
+	return resp, nil
+}
+
+func page(id, title string, parent *api.Parent) api.Page {
+	return api.Page{
+		Object: "page",
+		ID:     id,
+		Parent: parent,
+		Properties: map[string]api.Property{
+			"title": {Type: "title", Title: []api.RichText{{PlainText: title}}},
+		},
+	}
+}
+
+func database(id, title string, parent *api.Parent) api.Page {
+	return api.Page{Object: "database", ID: id, Parent: parent, DatabaseTitle: []api.RichText{{PlainText: title}}}
+}
+
+func pageParent(id string) *api.Parent {
+	return &api.Parent{Type: "page_id", PageID: id}
+}
+
+func databaseParent(id string) *api.Parent {
+	return &api.Parent{Type: "database_id", DatabaseID: id}
+}
+
+var workspaceParent = &api.Parent{Type: "workspace", Workspace: true}
+
+func TestBuild(t *testing.T) {
+	tree := Build([]api.Page{
+		page("wiki", "Wiki", workspaceParent),
+		page("guides", "Guides", pageParent("wiki")),
+		page("about", "About", pageParent("wiki")),
+		database("tasks", "Tasks", pageParent("wiki")),
+		page("task-1", "Write docs", databaseParent("tasks")),
+		page("orphan", "Shared Alone", pageParent("not-shared")),
+	})
+
+	if tree.Len() != 6 {
+		t.Errorf("Expected 6 nodes, got %d", tree.Len())
+	}
+	if len(tree.Roots) != 2 || tree.Roots[0].ID != "orphan" || tree.Roots[1].ID != "wiki" {
+		t.Fatalf("Expected the orphan and wiki pages as roots, got %+v", tree.Roots)
+	}
+
+	var children []string
+	for _, child := range tree.Roots[1].Children {
+		children = append(children, child.Title)
+	}
+	if !reflect.DeepEqual(children, []string{"About", "Guides", "Tasks"}) {
+		t.Errorf("Expected the wiki's children sorted by title, got %v", children)
+	}
+	if tasks := tree.Roots[1].Children[2]; len(tasks.Children) != 1 || tasks.Children[0].Parent != tasks {
+		t.Errorf("Expected the database row to be a child of the database")
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tree := Build([]api.Page{
+		page("wiki", "Wiki", workspaceParent),
+		page("guides", "Guides", pageParent("wiki")),
+		database("tasks", "Team Tasks", pageParent("wiki")),
+		page("task-1", "Write docs", databaseParent("tasks")),
+		page("task-1-notes", "Notes", pageParent("task-1")),
+		page("untitled", "", workspaceParent),
+	})
+
+	dir := "out"
+	expected := []crawl.Task{
+		{PageID: "untitled", Name: "untitled", Dir: dir},
+		{PageID: "wiki", Name: "wiki", Dir: dir},
+		{PageID: "task-1", Name: "write-docs", Dir: filepath.Join(dir, "team-tasks")},
+	}
+	if tasks := tree.Tasks(dir); !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Expected tasks %+v, got %+v", expected, tasks)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	mockAPI := &mockNotionAPI{pages: [][]api.Page{
+		{page("wiki", "Wiki", workspaceParent)},
+		{page("guides", "Guides", pageParent("wiki"))},
+	}}
+
+	tree, err := Discover(mockAPI, "", "", "test-token")
+	if err != nil {
+		t.Fatalf("Did not expect an error but got one: %v", err)
+	}
+	if tree.Len() != 2 || len(tree.Roots) != 1 || len(tree.Roots[0].Children) != 1 {
+		t.Errorf("Expected the child page from the second page of results under the wiki, got %+v", tree.Roots)
+	}
+}
+
+func TestParseObjectType(t *testing.T) {
+	for _, input := range []string{"", "page", "Database"} {
+		if _, err := ParseObjectType(input); err != nil {
+			t.Errorf("Did not expect an error for %q but got one: %v", input, err)
+		}
+	}
+	if _, err := ParseObjectType("block"); err == nil {
+		t.Errorf("Expected an error but did not get one")
+	}
+}